@@ -3,11 +3,16 @@ package ast
 import (
 	"bytes"
 	"cprieto.com/monkey/token"
+	"fmt"
+	"strings"
+	"unicode"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos is the position of the first token of the node in the source
+	Pos() token.Position
 }
 
 type Statement interface {
@@ -18,6 +23,24 @@ type Expression interface {
 	Node
 }
 
+// IsStatement and IsExpression tell the node kinds apart, the Statement and
+// Expression interfaces are the same and accept any node
+func IsStatement(node Node) bool {
+	switch node.(type) {
+	case *LetStatement, *ReturnStatement, *ExpressionStatement, *BadStatement:
+		return true
+	}
+	return false
+}
+
+func IsExpression(node Node) bool {
+	switch node.(type) {
+	case *Program, *BlockStatement:
+		return false
+	}
+	return !IsStatement(node)
+}
+
 /// ** Program statement
 
 type Program struct {
@@ -41,6 +64,13 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 /// ** Identifier
 
 type Identifier struct {
@@ -52,6 +82,10 @@ func (i Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i Identifier) Pos() token.Position {
+	return i.Token.Start
+}
+
 func (i Identifier) String() string {
 	return i.Value
 }
@@ -67,15 +101,153 @@ func (i IntegerLiteral) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i IntegerLiteral) Pos() token.Position {
+	return i.Token.Start
+}
+
 func (i IntegerLiteral) String() string {
 	return i.Token.Literal
 }
 
+/// ** Float literals
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (f *FloatLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *FloatLiteral) Pos() token.Position {
+	return f.Token.Start
+}
+
+func (f *FloatLiteral) String() string {
+	return f.Token.Literal
+}
+
+/// ** Boolean literals
+
+type Boolean struct {
+	Token token.Token
+	Value bool
+}
+
+func (b *Boolean) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Start
+}
+
+func (b *Boolean) String() string {
+	return b.Token.Literal
+}
+
+/// ** String literals
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (s *StringLiteral) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+func (s *StringLiteral) Pos() token.Position {
+	return s.Token.Start
+}
+
+func (s *StringLiteral) String() string {
+	return QuoteString(s.Value)
+}
+
+// QuoteString renders a string value back as a Monkey string literal,
+// escaping whatever the lexer would otherwise not read back verbatim
+func QuoteString(value string) string {
+	var out bytes.Buffer
+
+	out.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"':
+			out.WriteString(`\"`)
+		case r == '\\':
+			out.WriteString(`\\`)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case !unicode.IsPrint(r):
+			out.WriteString(fmt.Sprintf(`\u{%x}`, r))
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
+
+/// ** Array literals
+
+type ArrayLiteral struct {
+	Token    token.Token
+	Elements []Expression
+}
+
+func (a *ArrayLiteral) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+func (a *ArrayLiteral) Pos() token.Position {
+	return a.Token.Start
+}
+
+func (a *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+/// ** Bad statement
+
+// BadStatement stands in for a statement that failed to parse, it spans
+// from its token to End so tools can still locate the broken source
+type BadStatement struct {
+	Token token.Token
+	End   token.Position
+}
+
+func (b *BadStatement) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+func (b *BadStatement) Pos() token.Position {
+	return b.Token.Start
+}
+
+func (b *BadStatement) String() string {
+	return "<bad statement>"
+}
+
 /// ** LET statements
 
 type LetStatement struct {
 	Token token.Token
-	// TODO: use identifier
 	Name  *Identifier
 	Value Expression
 }
@@ -86,7 +258,9 @@ func (l *LetStatement) String() string {
 	out.WriteString(l.Name.Value)
 	out.WriteString(" = ")
 
-	// TODO: Put value
+	if l.Value != nil {
+		out.WriteString(l.Value.String())
+	}
 
 	out.WriteString(";")
 
@@ -97,6 +271,10 @@ func (l *LetStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+func (l *LetStatement) Pos() token.Position {
+	return l.Token.Start
+}
+
 /// ** RETURN statement
 
 type ReturnStatement struct {
@@ -108,7 +286,9 @@ func (l *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(l.TokenLiteral() + " ")
 
-	// TODO: Handle expression
+	if l.Value != nil {
+		out.WriteString(l.Value.String())
+	}
 
 	out.WriteString(";")
 
@@ -119,6 +299,10 @@ func (l *ReturnStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+func (l *ReturnStatement) Pos() token.Position {
+	return l.Token.Start
+}
+
 /// ** Expression statement
 
 type ExpressionStatement struct {
@@ -130,10 +314,15 @@ func (e ExpressionStatement) TokenLiteral() string {
 	return e.Token.Literal
 }
 
+func (e ExpressionStatement) Pos() token.Position {
+	return e.Token.Start
+}
+
 func (e ExpressionStatement) String() string {
-	var out bytes.Buffer
-	// TODO: Implement expression
-	return out.String()
+	if e.Expression != nil {
+		return e.Expression.String()
+	}
+	return ""
 }
 
 /// ** Prefix expression
@@ -148,6 +337,10 @@ func (p *PrefixExpression) TokenLiteral() string {
 	return p.Token.Literal
 }
 
+func (p *PrefixExpression) Pos() token.Position {
+	return p.Token.Start
+}
+
 func (p *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -158,3 +351,223 @@ func (p *PrefixExpression) String() string {
 
 	return out.String()
 }
+
+/// ** Infix expression
+
+type InfixExpression struct {
+	Token    token.Token
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (i *InfixExpression) TokenLiteral() string {
+	return i.Token.Literal
+}
+
+func (i *InfixExpression) Pos() token.Position {
+	return i.Left.Pos()
+}
+
+func (i *InfixExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(i.Left.String())
+	out.WriteString(" " + i.Operator + " ")
+	out.WriteString(i.Right.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+/// ** Block statement
+
+type BlockStatement struct {
+	Token      token.Token
+	Statements []Statement
+}
+
+func (b *BlockStatement) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+func (b *BlockStatement) Pos() token.Position {
+	return b.Token.Start
+}
+
+func (b *BlockStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("{ ")
+	for _, s := range b.Statements {
+		out.WriteString(s.String())
+	}
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+/// ** If expression
+
+type IfExpression struct {
+	Token       token.Token
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (i *IfExpression) TokenLiteral() string {
+	return i.Token.Literal
+}
+
+func (i *IfExpression) Pos() token.Position {
+	return i.Token.Start
+}
+
+func (i *IfExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("if ")
+	out.WriteString(i.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(i.Consequence.String())
+
+	if i.Alternative != nil {
+		out.WriteString(" else ")
+		out.WriteString(i.Alternative.String())
+	}
+
+	return out.String()
+}
+
+/// ** Function literal
+
+type FunctionLiteral struct {
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+	// Name is the name a `let` binds the function to, empty when anonymous
+	Name string
+}
+
+func (f *FunctionLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *FunctionLiteral) Pos() token.Position {
+	return f.Token.Start
+}
+
+func (f *FunctionLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(f.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+/// ** Call expression
+
+type CallExpression struct {
+	Token     token.Token
+	Function  Expression
+	Arguments []Expression
+}
+
+func (c *CallExpression) TokenLiteral() string {
+	return c.Token.Literal
+}
+
+func (c *CallExpression) Pos() token.Position {
+	return c.Function.Pos()
+}
+
+func (c *CallExpression) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range c.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(c.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+/// ** Index expression
+
+type IndexExpression struct {
+	Token token.Token
+	Left  Expression
+	Index Expression
+}
+
+func (i *IndexExpression) TokenLiteral() string {
+	return i.Token.Literal
+}
+
+func (i *IndexExpression) Pos() token.Position {
+	return i.Left.Pos()
+}
+
+func (i *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(i.Left.String())
+	out.WriteString("[")
+	out.WriteString(i.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+/// ** Hash literal
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// HashLiteral keeps its pairs in source order
+type HashLiteral struct {
+	Token token.Token
+	Pairs []HashPair
+}
+
+func (h *HashLiteral) TokenLiteral() string {
+	return h.Token.Literal
+}
+
+func (h *HashLiteral) Pos() token.Position {
+	return h.Token.Start
+}
+
+func (h *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -0,0 +1,109 @@
+package ast
+
+// ModifierFunc returns the node taking the place of the one it is given
+type ModifierFunc func(Node) Node
+
+// Modify rewrites an AST bottom up: the children of node are modified
+// first, then node itself is handed to modifier and its result returned.
+// A statement the modifier turns into nil is removed from its program or
+// block. Results that cannot take the place of the original node, like a
+// statement where an expression is expected, leave the original in place.
+func Modify(node Node, modifier ModifierFunc) Node {
+	if isNil(node) {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *BlockStatement:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *LetStatement:
+		if n.Name != nil {
+			if name, ok := Modify(n.Name, modifier).(*Identifier); ok {
+				n.Name = name
+			}
+		}
+		if n.Value != nil {
+			n.Value = modifyExpression(n.Value, modifier)
+		}
+	case *ReturnStatement:
+		if n.Value != nil {
+			n.Value = modifyExpression(n.Value, modifier)
+		}
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			n.Expression = modifyExpression(n.Expression, modifier)
+		}
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		if n.Alternative != nil {
+			n.Alternative = modifyBlock(n.Alternative, modifier)
+		}
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			if param, ok := Modify(p, modifier).(*Identifier); ok {
+				n.Parameters[i] = param
+			}
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		n.Arguments = modifyExpressions(n.Arguments, modifier)
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+	case *ArrayLiteral:
+		n.Elements = modifyExpressions(n.Elements, modifier)
+	case *HashLiteral:
+		for i, pair := range n.Pairs {
+			n.Pairs[i] = HashPair{
+				Key:   modifyExpression(pair.Key, modifier),
+				Value: modifyExpression(pair.Value, modifier),
+			}
+		}
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) []Statement {
+	modified := stmts[:0]
+	for _, s := range stmts {
+		switch m := Modify(s, modifier); {
+		case m == nil:
+		case IsStatement(m):
+			modified = append(modified, m)
+		default:
+			modified = append(modified, s)
+		}
+	}
+	return modified
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) []Expression {
+	for i, e := range exps {
+		exps[i] = modifyExpression(e, modifier)
+	}
+	return exps
+}
+
+func modifyExpression(e Expression, modifier ModifierFunc) Expression {
+	if modified := Modify(e, modifier); modified != nil && IsExpression(modified) {
+		return modified
+	}
+	return e
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if modified, ok := Modify(block, modifier).(*BlockStatement); ok {
+		return modified
+	}
+	return block
+}
@@ -0,0 +1,98 @@
+package ast_test
+
+import (
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/token"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	turnOneIntoTwo := func(n ast.Node) ast.Node {
+		integer, ok := n.(*ast.IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return n
+		}
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1", "2"},
+		{"1 + 2", "(2 + 2)"},
+		{"-1", "(-2)"},
+		{"a[1]", "(a[2])"},
+		{"if (1) { 1 } else { 1 }", "if 2 { 2 } else { 2 }"},
+		{"return 1;", "return 2;"},
+		{"let a = 1;", "let a = 2;"},
+		{"fn(a) { 1 }", "fn(a) { 2 }"},
+		{"f(1, 3)", "f(2, 3)"},
+		{"[1, 1]", "[2, 2]"},
+		{"{1: 1}", "{2: 2}"},
+	}
+
+	for _, tt := range tests {
+		modified := ast.Modify(parse(t, tt.input), turnOneIntoTwo)
+
+		if modified.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, modified.String())
+		}
+	}
+}
+
+func TestModifyRemovesStatements(t *testing.T) {
+	dropLets := func(n ast.Node) ast.Node {
+		if _, ok := n.(*ast.LetStatement); ok {
+			return nil
+		}
+		return n
+	}
+
+	modified := ast.Modify(parse(t, "let a = 1; a; fn() { let b = 2; b }"), dropLets)
+
+	if modified.String() != "afn() { b }" {
+		t.Errorf("Expected `afn() { b }` but got `%s`", modified.String())
+	}
+}
+
+func TestModifyKeepsMismatchedResults(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		modifier ast.ModifierFunc
+	}{
+		{"if (a) { b }", "if a { b }", func(n ast.Node) ast.Node {
+			if _, ok := n.(*ast.BlockStatement); ok {
+				return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "0"}}
+			}
+			return n
+		}},
+		{"a + b; f(a)", "(a + b)f(a)", func(n ast.Node) ast.Node {
+			if ident, ok := n.(*ast.Identifier); ok && ident.Value == "a" {
+				return &ast.ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}, Value: ident}
+			}
+			return n
+		}},
+		{"[a]", "[a]", func(n ast.Node) ast.Node {
+			if _, ok := n.(*ast.Identifier); ok {
+				return &ast.Program{}
+			}
+			return n
+		}},
+		{"a; b", "ab", func(n ast.Node) ast.Node {
+			if stmt, ok := n.(*ast.ExpressionStatement); ok {
+				return stmt.Expression
+			}
+			return n
+		}},
+	}
+
+	for _, tt := range tests {
+		modified := ast.Modify(parse(t, tt.input), tt.modifier)
+
+		if modified.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, modified.String())
+		}
+	}
+}
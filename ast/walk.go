@@ -0,0 +1,115 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of
+// node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, children are visited in
+// source order. Nil children are skipped.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		walkChild(v, n.Name)
+		walkChild(v, n.Value)
+	case *ReturnStatement:
+		walkChild(v, n.Value)
+	case *ExpressionStatement:
+		walkChild(v, n.Expression)
+	case *PrefixExpression:
+		walkChild(v, n.Right)
+	case *InfixExpression:
+		walkChild(v, n.Left)
+		walkChild(v, n.Right)
+	case *IfExpression:
+		walkChild(v, n.Condition)
+		walkChild(v, n.Consequence)
+		walkChild(v, n.Alternative)
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			walkChild(v, p)
+		}
+		walkChild(v, n.Body)
+	case *CallExpression:
+		walkChild(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *IndexExpression:
+		walkChild(v, n.Left)
+		walkChild(v, n.Index)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walkChild(v, pair.Key)
+			walkChild(v, pair.Value)
+		}
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *Boolean, *StringLiteral, *BadStatement:
+		// nothing to do
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// walkChild walks a child unless it is missing
+func walkChild(v Visitor, node Node) {
+	if !isNil(node) {
+		Walk(v, node)
+	}
+}
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, s := range stmts {
+		walkChild(v, s)
+	}
+}
+
+func walkExpressions(v Visitor, exps []Expression) {
+	for _, e := range exps {
+		walkChild(v, e)
+	}
+}
+
+// isNil tells if a node is missing, children typed as a block or an
+// identifier are nil pointers rather than nil interfaces
+func isNil(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *BlockStatement:
+		return n == nil
+	case *Identifier:
+		return n == nil
+	}
+	return false
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,133 @@
+package ast_test
+
+import (
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/lexer"
+	"cprieto.com/monkey/parser"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+	}
+
+	return program
+}
+
+func TestInspect(t *testing.T) {
+	program := parse(t, `let f = fn(a) { if (a) { [a, {"k": -a}] } else { f(a)[0] } }; return 1.5 + true;`)
+
+	var visited []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			visited = append(visited, strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+		}
+		return true
+	})
+
+	expected := "Program LetStatement Identifier FunctionLiteral Identifier BlockStatement " +
+		"ExpressionStatement IfExpression Identifier BlockStatement ExpressionStatement ArrayLiteral " +
+		"Identifier HashLiteral StringLiteral PrefixExpression Identifier BlockStatement " +
+		"ExpressionStatement IndexExpression CallExpression Identifier Identifier IntegerLiteral " +
+		"ReturnStatement InfixExpression FloatLiteral Boolean"
+
+	if strings.Join(visited, " ") != expected {
+		t.Errorf("Wrong visiting order, expected\n%s\nbut got\n%s", expected, strings.Join(visited, " "))
+	}
+}
+
+func TestInspectPrune(t *testing.T) {
+	program := parse(t, "let a = fn(x) { y }; b")
+
+	var identifiers []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok {
+			identifiers = append(identifiers, ident.Value)
+		}
+		_, isFunction := n.(*ast.FunctionLiteral)
+		return !isFunction
+	})
+
+	if strings.Join(identifiers, " ") != "a b" {
+		t.Errorf("Expected identifiers `a b` but got `%s`", strings.Join(identifiers, " "))
+	}
+}
+
+type depthVisitor struct {
+	depth *int
+	max   *int
+}
+
+func (v depthVisitor) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		*v.depth--
+		return nil
+	}
+
+	*v.depth++
+	if *v.depth > *v.max {
+		*v.max = *v.depth
+	}
+	return v
+}
+
+func TestWalkBalanced(t *testing.T) {
+	depth, max := 0, 0
+	ast.Walk(depthVisitor{&depth, &max}, parse(t, "1 + (2 * -3)"))
+
+	if depth != 0 {
+		t.Errorf("Expected every node to be left but depth is %d", depth)
+	}
+
+	// Program, ExpressionStatement, + , *, -, 3
+	if max != 6 {
+		t.Errorf("Expected a maximum depth of 6 but got %d", max)
+	}
+}
+
+func TestNilChildren(t *testing.T) {
+	a := &ast.Identifier{Value: "a"}
+
+	// identifiers counts the identifiers visited, missing children are skipped
+	tests := []struct {
+		node        ast.Node
+		identifiers int
+	}{
+		{&ast.LetStatement{Value: a}, 1},
+		{&ast.ReturnStatement{}, 0},
+		{&ast.ExpressionStatement{}, 0},
+		{&ast.PrefixExpression{Operator: "-"}, 0},
+		{&ast.InfixExpression{Right: a, Operator: "+"}, 1},
+		{&ast.IfExpression{Condition: a}, 1},
+		{&ast.FunctionLiteral{Parameters: []*ast.Identifier{nil, a}}, 1},
+		{&ast.CallExpression{Arguments: []ast.Expression{nil, a}}, 1},
+		{&ast.IndexExpression{Left: a}, 1},
+		{&ast.ArrayLiteral{Elements: []ast.Expression{a, nil}}, 1},
+		{&ast.HashLiteral{Pairs: []ast.HashPair{{Value: a}}}, 1},
+		{&ast.BlockStatement{Statements: []ast.Statement{nil}}, 0},
+	}
+
+	for _, tt := range tests {
+		identifiers := 0
+		ast.Inspect(tt.node, func(n ast.Node) bool {
+			if _, ok := n.(*ast.Identifier); ok {
+				identifiers++
+			}
+			return true
+		})
+
+		if identifiers != tt.identifiers {
+			t.Errorf("%T: expected %d identifiers visited but got %d", tt.node, tt.identifiers, identifiers)
+		}
+
+		// Modify must not panic either
+		ast.Modify(tt.node, func(n ast.Node) ast.Node { return n })
+	}
+}
@@ -0,0 +1,248 @@
+// Package astjson encodes Monkey syntax trees as JSON and decodes them
+// back, so tools outside Go can work on parsed programs.
+//
+// Every node is an object with its "kind" (the ast type name, like
+// "InfixExpression"), its "token", its "pos" and the fields holding its
+// children and values:
+//
+//	Program              statements
+//	BlockStatement       statements
+//	LetStatement         name, value
+//	ReturnStatement      value
+//	ExpressionStatement  expression
+//	BadStatement         end
+//	Identifier           value
+//	IntegerLiteral       value, a decimal string
+//	FloatLiteral         value
+//	Boolean              value
+//	StringLiteral        value
+//	ArrayLiteral         elements
+//	HashLiteral          pairs, a list of {"key", "value"} objects
+//	PrefixExpression     operator, right
+//	InfixExpression      left, operator, right
+//	IfExpression         condition, consequence, alternative
+//	FunctionLiteral      name, parameters, body
+//	CallExpression       function, arguments
+//	IndexExpression      left, index
+//
+// Tokens are {"type", "literal", "start", "end"} and positions are
+// {"filename", "offset", "line", "column"}, the filename left out when
+// unknown. Missing children, like the alternative of an if, are null. The
+// "pos" of a node is informative, decoding ignores it. Integer values are
+// strings as JSON numbers lose precision above 2^53 in JavaScript.
+package astjson
+
+import (
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/token"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+type jsonPosition struct {
+	Filename string `json:"filename,omitempty"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+type jsonToken struct {
+	Type    string       `json:"type"`
+	Literal string       `json:"literal"`
+	Start   jsonPosition `json:"start"`
+	End     jsonPosition `json:"end"`
+}
+
+type object map[string]interface{}
+
+// Marshal encodes node and all its children
+func Marshal(node ast.Node) ([]byte, error) {
+	encoded, err := encode(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encoded)
+}
+
+// MarshalIndent is like Marshal but indents the output
+func MarshalIndent(node ast.Node, prefix, indent string) ([]byte, error) {
+	encoded, err := encode(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(encoded, prefix, indent)
+}
+
+func encodePosition(pos token.Position) jsonPosition {
+	return jsonPosition{Filename: pos.Filename, Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+}
+
+func encodeToken(tok token.Token) jsonToken {
+	return jsonToken{
+		Type:    string(tok.Type),
+		Literal: tok.Literal,
+		Start:   encodePosition(tok.Start),
+		End:     encodePosition(tok.End),
+	}
+}
+
+func encode(node ast.Node) (interface{}, error) {
+	var (
+		kind  string
+		tok   token.Token
+		attrs = object{}
+		err   error
+	)
+
+	// children are encoded into attrs, the first failure is kept in err
+	child := func(name string, n ast.Node) {
+		if err != nil {
+			return
+		}
+		attrs[name], err = encode(n)
+	}
+	children := func(name string, nodes []ast.Node) {
+		list := []interface{}{}
+		for _, n := range nodes {
+			if err != nil {
+				return
+			}
+			var encoded interface{}
+			encoded, err = encode(n)
+			list = append(list, encoded)
+		}
+		attrs[name] = list
+	}
+
+	switch n := node.(type) {
+	case nil:
+		return nil, nil
+	case *ast.Program:
+		kind = "Program"
+		children("statements", statementNodes(n.Statements))
+	case *ast.BlockStatement:
+		if n == nil {
+			return nil, nil
+		}
+		kind, tok = "BlockStatement", n.Token
+		children("statements", statementNodes(n.Statements))
+	case *ast.LetStatement:
+		kind, tok = "LetStatement", n.Token
+		if n.Name != nil {
+			child("name", n.Name)
+		} else {
+			attrs["name"] = nil
+		}
+		child("value", n.Value)
+	case *ast.ReturnStatement:
+		kind, tok = "ReturnStatement", n.Token
+		child("value", n.Value)
+	case *ast.ExpressionStatement:
+		kind, tok = "ExpressionStatement", n.Token
+		child("expression", n.Expression)
+	case *ast.BadStatement:
+		kind, tok = "BadStatement", n.Token
+		attrs["end"] = encodePosition(n.End)
+	case *ast.Identifier:
+		kind, tok = "Identifier", n.Token
+		attrs["value"] = n.Value
+	case *ast.IntegerLiteral:
+		kind, tok = "IntegerLiteral", n.Token
+		attrs["value"] = strconv.FormatInt(n.Value, 10)
+	case *ast.FloatLiteral:
+		kind, tok = "FloatLiteral", n.Token
+		attrs["value"] = n.Value
+	case *ast.Boolean:
+		kind, tok = "Boolean", n.Token
+		attrs["value"] = n.Value
+	case *ast.StringLiteral:
+		kind, tok = "StringLiteral", n.Token
+		attrs["value"] = n.Value
+	case *ast.ArrayLiteral:
+		kind, tok = "ArrayLiteral", n.Token
+		children("elements", expressionNodes(n.Elements))
+	case *ast.HashLiteral:
+		kind, tok = "HashLiteral", n.Token
+		pairs := []interface{}{}
+		for _, pair := range n.Pairs {
+			key, keyErr := encode(pair.Key)
+			value, valueErr := encode(pair.Value)
+			if keyErr != nil || valueErr != nil {
+				return nil, firstError(keyErr, valueErr)
+			}
+			pairs = append(pairs, object{"key": key, "value": value})
+		}
+		attrs["pairs"] = pairs
+	case *ast.PrefixExpression:
+		kind, tok = "PrefixExpression", n.Token
+		attrs["operator"] = n.Operator
+		child("right", n.Right)
+	case *ast.InfixExpression:
+		kind, tok = "InfixExpression", n.Token
+		attrs["operator"] = n.Operator
+		child("left", n.Left)
+		child("right", n.Right)
+	case *ast.IfExpression:
+		kind, tok = "IfExpression", n.Token
+		child("condition", n.Condition)
+		child("consequence", n.Consequence)
+		child("alternative", n.Alternative)
+	case *ast.FunctionLiteral:
+		kind, tok = "FunctionLiteral", n.Token
+		attrs["name"] = n.Name
+		params := []ast.Node{}
+		for _, p := range n.Parameters {
+			params = append(params, p)
+		}
+		children("parameters", params)
+		child("body", n.Body)
+	case *ast.CallExpression:
+		kind, tok = "CallExpression", n.Token
+		child("function", n.Function)
+		children("arguments", expressionNodes(n.Arguments))
+	case *ast.IndexExpression:
+		kind, tok = "IndexExpression", n.Token
+		child("left", n.Left)
+		child("index", n.Index)
+	default:
+		return nil, fmt.Errorf("astjson: unsupported node type %T", node)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	attrs["kind"] = kind
+	attrs["pos"] = encodePosition(node.Pos())
+	if kind != "Program" {
+		attrs["token"] = encodeToken(tok)
+	}
+
+	return attrs, nil
+}
+
+func statementNodes(stmts []ast.Statement) []ast.Node {
+	nodes := make([]ast.Node, len(stmts))
+	for i, s := range stmts {
+		nodes[i] = s
+	}
+	return nodes
+}
+
+func expressionNodes(exps []ast.Expression) []ast.Node {
+	nodes := make([]ast.Node, len(exps))
+	for i, e := range exps {
+		nodes[i] = e
+	}
+	return nodes
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
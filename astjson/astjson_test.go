@@ -0,0 +1,121 @@
+package astjson
+
+import (
+	"bytes"
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/lexer"
+	"cprieto.com/monkey/parser"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.NewFile("test.mk", input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+	}
+
+	return program
+}
+
+func TestMarshal(t *testing.T) {
+	encoded, err := Marshal(parse(t, "-x"))
+	if err != nil {
+		t.Fatalf("Encoding error not expected: %s", err)
+	}
+
+	expected := `{"kind":"Program","pos":{"filename":"test.mk","offset":0,"line":1,"column":1},"statements":[` +
+		`{"expression":{"kind":"PrefixExpression","operator":"-","pos":{"filename":"test.mk","offset":0,"line":1,"column":1},` +
+		`"right":{"kind":"Identifier","pos":{"filename":"test.mk","offset":1,"line":1,"column":2},` +
+		`"token":{"type":"IDENT","literal":"x","start":{"filename":"test.mk","offset":1,"line":1,"column":2},"end":{"filename":"test.mk","offset":2,"line":1,"column":3}},"value":"x"},` +
+		`"token":{"type":"-","literal":"-","start":{"filename":"test.mk","offset":0,"line":1,"column":1},"end":{"filename":"test.mk","offset":1,"line":1,"column":2}}},` +
+		`"kind":"ExpressionStatement","pos":{"filename":"test.mk","offset":0,"line":1,"column":1},` +
+		`"token":{"type":"-","literal":"-","start":{"filename":"test.mk","offset":0,"line":1,"column":1},"end":{"filename":"test.mk","offset":1,"line":1,"column":2}}}]}`
+
+	if string(encoded) != expected {
+		t.Errorf("Wrong encoding, expected\n%s\nbut got\n%s", expected, encoded)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	input := `let fact = fn(n) { if (n < 2) { return 1; } else { n * fact(n - 1) } };
+let data = {"list": [1, 2.5, true, "s"], 3: !false};
+data["list"][0] + fact(0x10) + 9007199254740993;
+if (x) { }`
+
+	program := parse(t, input)
+
+	encoded, err := Marshal(program)
+	if err != nil {
+		t.Fatalf("Encoding error not expected: %s", err)
+	}
+
+	decoded, err := Unmarshal(encoded)
+	if err != nil {
+		t.Fatalf("Decoding error not expected: %s", err)
+	}
+
+	if decoded.String() != program.String() {
+		t.Errorf("Expected program `%s` but got `%s`", program, decoded)
+	}
+
+	reencoded, err := Marshal(decoded)
+	if err != nil {
+		t.Fatalf("Encoding error not expected: %s", err)
+	}
+
+	if !bytes.Equal(encoded, reencoded) {
+		t.Errorf("Encoding is not stable, expected\n%s\nbut got\n%s", encoded, reencoded)
+	}
+
+	// integers beyond 2^53 stay exact for JavaScript readers
+	if !bytes.Contains(encoded, []byte(`"value":"9007199254740993"`)) {
+		t.Errorf("Expected 9007199254740993 encoded as a string in\n%s", encoded)
+	}
+
+	fact := decoded.(*ast.Program).Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if fact.Name != "fact" {
+		t.Errorf("Expected function name `fact` but got `%s`", fact.Name)
+	}
+	if pos := fact.Body.Pos().String(); pos != "test.mk:1:18" {
+		t.Errorf("Expected body position test.mk:1:18 but got %s", pos)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[]`, "cannot unmarshal array"},
+		{`{"kind": "Nope"}`, `unknown node kind "Nope"`},
+		{`{"kind": "IntegerLiteral", "token": {}, "value": 1}`, "cannot unmarshal number"},
+		{`{"kind": "IntegerLiteral", "token": {}, "value": "1.5"}`, `IntegerLiteral "value": strconv.ParseInt`},
+		{`{"statements": []}`, `node without "kind"`},
+		{`{"kind": "Program"}`, `Program without "statements"`},
+		{`{"kind": "Program", "statements": [null]}`, "holds a null node"},
+		{`{"kind": "ReturnStatement", "token": {}, "value": null}`, `ReturnStatement "value" must not be null`},
+		{`{"kind": "LetStatement", "token": {}, "name": {"kind": "Boolean", "token": {}, "value": true}, "value": null}`, "must be an Identifier"},
+		{`{"kind": "IfExpression", "token": {}, "condition": {"kind": "Boolean", "token": {}, "value": true}, "consequence": null, "alternative": null}`, "must be a BlockStatement"},
+		{`{"kind": "InfixExpression", "token": {}, "operator": "+", "left": {"kind": "Program", "statements": []}, "right": {"kind": "Boolean", "token": {}, "value": true}}`, "must be an expression"},
+		{`{"kind": "PrefixExpression", "token": {}, "operator": "-", "right": {"kind": "LetStatement", "token": {}, "name": {"kind": "Identifier", "token": {}, "value": "x"}, "value": {"kind": "Boolean", "token": {}, "value": true}}}`, "must be an expression"},
+		{`{"kind": "ArrayLiteral", "token": {}, "elements": [{"kind": "BlockStatement", "token": {}, "statements": []}]}`, "must hold expressions"},
+		{`{"kind": "Program", "statements": [{"kind": "Boolean", "token": {}, "value": true}]}`, "must hold statements"},
+		{`{"kind": "BlockStatement", "token": {}, "statements": [{"kind": "Program", "statements": []}]}`, "must hold statements"},
+	}
+
+	for _, tt := range tests {
+		_, err := Unmarshal([]byte(tt.input))
+		if err == nil {
+			t.Errorf("Expected an error for %s but got none", tt.input)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected error containing `%s` but got `%s`", tt.expected, err)
+		}
+	}
+}
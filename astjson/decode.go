@@ -0,0 +1,278 @@
+package astjson
+
+import (
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/token"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Unmarshal rebuilds a tree encoded by Marshal
+func Unmarshal(data []byte) (ast.Node, error) {
+	return decode(json.RawMessage(data))
+}
+
+type decoder struct {
+	fields map[string]json.RawMessage
+	kind   string
+	err    error
+}
+
+// field decodes the named field into v, the first failure is kept
+func (d *decoder) field(name string, v interface{}) {
+	if d.err != nil {
+		return
+	}
+
+	raw, ok := d.fields[name]
+	if !ok {
+		d.err = fmt.Errorf("astjson: %s without %q", d.kind, name)
+		return
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		d.err = fmt.Errorf("astjson: %s %q: %w", d.kind, name, err)
+	}
+}
+
+func (d *decoder) token() token.Token {
+	var tok jsonToken
+	d.field("token", &tok)
+
+	return token.Token{
+		Type:    token.TokenType(tok.Type),
+		Literal: tok.Literal,
+		Start:   decodePosition(tok.Start),
+		End:     decodePosition(tok.End),
+	}
+}
+
+func (d *decoder) string(name string) string {
+	var s string
+	d.field(name, &s)
+	return s
+}
+
+// integer decodes an int64 written as a decimal string
+func (d *decoder) integer(name string) int64 {
+	s := d.string(name)
+	if d.err != nil {
+		return 0
+	}
+
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		d.err = fmt.Errorf("astjson: %s %q: %w", d.kind, name, err)
+	}
+	return value
+}
+
+// node decodes a child, it is nil when the field is null
+func (d *decoder) node(name string) ast.Node {
+	var raw json.RawMessage
+	d.field(name, &raw)
+	if d.err != nil {
+		return nil
+	}
+
+	n, err := decode(raw)
+	if err != nil {
+		d.err = err
+	}
+	return n
+}
+
+func (d *decoder) nodes(name string) []ast.Node {
+	var raws []json.RawMessage
+	d.field(name, &raws)
+
+	nodes := []ast.Node{}
+	for _, raw := range raws {
+		if d.err != nil {
+			return nil
+		}
+
+		n, err := decode(raw)
+		if err != nil {
+			d.err = err
+		} else if n == nil {
+			d.err = fmt.Errorf("astjson: %s %q holds a null node", d.kind, name)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+func (d *decoder) statements(name string) []ast.Statement {
+	stmts := []ast.Statement{}
+	for _, n := range d.nodes(name) {
+		if !ast.IsStatement(n) && d.err == nil {
+			d.err = fmt.Errorf("astjson: %s %q must hold statements, got %T", d.kind, name, n)
+		}
+		stmts = append(stmts, n)
+	}
+	return stmts
+}
+
+func (d *decoder) expressions(name string) []ast.Expression {
+	exps := []ast.Expression{}
+	for _, n := range d.nodes(name) {
+		if !ast.IsExpression(n) && d.err == nil {
+			d.err = fmt.Errorf("astjson: %s %q must hold expressions, got %T", d.kind, name, n)
+		}
+		exps = append(exps, n)
+	}
+	return exps
+}
+
+func (d *decoder) identifier(name string) *ast.Identifier {
+	n := d.node(name)
+	ident, ok := n.(*ast.Identifier)
+	if !ok && d.err == nil {
+		d.err = fmt.Errorf("astjson: %s %q must be an Identifier, got %T", d.kind, name, n)
+	}
+	return ident
+}
+
+// block decodes a child block, null is only allowed when optional
+func (d *decoder) block(name string, optional bool) *ast.BlockStatement {
+	n := d.node(name)
+	if n == nil && optional {
+		return nil
+	}
+
+	block, ok := n.(*ast.BlockStatement)
+	if !ok && d.err == nil {
+		d.err = fmt.Errorf("astjson: %s %q must be a BlockStatement, got %T", d.kind, name, n)
+	}
+	return block
+}
+
+// expression decodes a child that must be there
+func (d *decoder) expression(name string) ast.Expression {
+	n := d.node(name)
+	switch {
+	case d.err != nil:
+	case n == nil:
+		d.err = fmt.Errorf("astjson: %s %q must not be null", d.kind, name)
+	case !ast.IsExpression(n):
+		d.err = fmt.Errorf("astjson: %s %q must be an expression, got %T", d.kind, name, n)
+	}
+	return n
+}
+
+func decode(raw json.RawMessage) (ast.Node, error) {
+	d := &decoder{}
+	if err := json.Unmarshal(raw, &d.fields); err != nil {
+		return nil, fmt.Errorf("astjson: %w", err)
+	}
+	if d.fields == nil {
+		return nil, nil // null
+	}
+
+	d.kind = "node"
+	d.kind = d.string("kind")
+
+	var node ast.Node
+
+	switch d.kind {
+	case "Program":
+		node = &ast.Program{Statements: d.statements("statements")}
+	case "BlockStatement":
+		node = &ast.BlockStatement{Token: d.token(), Statements: d.statements("statements")}
+	case "LetStatement":
+		node = &ast.LetStatement{Token: d.token(), Name: d.identifier("name"), Value: d.expression("value")}
+	case "ReturnStatement":
+		node = &ast.ReturnStatement{Token: d.token(), Value: d.expression("value")}
+	case "ExpressionStatement":
+		node = &ast.ExpressionStatement{Token: d.token(), Expression: d.expression("expression")}
+	case "BadStatement":
+		var end jsonPosition
+		d.field("end", &end)
+		node = &ast.BadStatement{Token: d.token(), End: decodePosition(end)}
+	case "Identifier":
+		node = &ast.Identifier{Token: d.token(), Value: d.string("value")}
+	case "IntegerLiteral":
+		node = &ast.IntegerLiteral{Token: d.token(), Value: d.integer("value")}
+	case "FloatLiteral":
+		var value float64
+		d.field("value", &value)
+		node = &ast.FloatLiteral{Token: d.token(), Value: value}
+	case "Boolean":
+		var value bool
+		d.field("value", &value)
+		node = &ast.Boolean{Token: d.token(), Value: value}
+	case "StringLiteral":
+		node = &ast.StringLiteral{Token: d.token(), Value: d.string("value")}
+	case "ArrayLiteral":
+		node = &ast.ArrayLiteral{Token: d.token(), Elements: d.expressions("elements")}
+	case "HashLiteral":
+		node = decodeHashLiteral(d)
+	case "PrefixExpression":
+		node = &ast.PrefixExpression{Token: d.token(), Operator: d.string("operator"), Right: d.expression("right")}
+	case "InfixExpression":
+		node = &ast.InfixExpression{
+			Token:    d.token(),
+			Left:     d.expression("left"),
+			Operator: d.string("operator"),
+			Right:    d.expression("right"),
+		}
+	case "IfExpression":
+		node = &ast.IfExpression{
+			Token:       d.token(),
+			Condition:   d.expression("condition"),
+			Consequence: d.block("consequence", false),
+			Alternative: d.block("alternative", true),
+		}
+	case "FunctionLiteral":
+		fn := &ast.FunctionLiteral{Token: d.token(), Parameters: []*ast.Identifier{}}
+		if _, ok := d.fields["name"]; ok {
+			fn.Name = d.string("name")
+		}
+		for _, p := range d.nodes("parameters") {
+			param, ok := p.(*ast.Identifier)
+			if !ok && d.err == nil {
+				d.err = fmt.Errorf("astjson: FunctionLiteral parameters must be Identifiers, got %T", p)
+			}
+			fn.Parameters = append(fn.Parameters, param)
+		}
+		fn.Body = d.block("body", false)
+		node = fn
+	case "CallExpression":
+		node = &ast.CallExpression{Token: d.token(), Function: d.expression("function"), Arguments: d.expressions("arguments")}
+	case "IndexExpression":
+		node = &ast.IndexExpression{Token: d.token(), Left: d.expression("left"), Index: d.expression("index")}
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("astjson: unknown node kind %q", d.kind)
+		}
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+func decodeHashLiteral(d *decoder) ast.Node {
+	hash := &ast.HashLiteral{Token: d.token(), Pairs: []ast.HashPair{}}
+
+	var pairs []map[string]json.RawMessage
+	d.field("pairs", &pairs)
+
+	for _, pair := range pairs {
+		if d.err != nil {
+			return nil
+		}
+
+		p := &decoder{fields: pair, kind: "HashLiteral pair"}
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: p.expression("key"), Value: p.expression("value")})
+		d.err = p.err
+	}
+
+	return hash
+}
+
+func decodePosition(pos jsonPosition) token.Position {
+	return token.Position{Filename: pos.Filename, Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+}
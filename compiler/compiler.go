@@ -0,0 +1,619 @@
+package compiler
+
+import (
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/code"
+	"cprieto.com/monkey/object"
+	"errors"
+	"fmt"
+)
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function being compiled,
+// the outermost scope is the main program
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	err error // the first operand found out of range
+}
+
+// Bytecode is what the compiler hands over to the VM, GlobalNames holds the
+// name of each global slot to report unbound identifiers
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	GlobalNames  []string
+}
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShl,
+	">>": code.OpShr,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	">=": code.OpGreaterEqual,
+	"<":  code.OpLessThan,
+	"<=": code.OpLessEqual,
+}
+
+// operandNames says what each operand of an opcode counts, to report the
+// limit hit when one does not fit its width
+var operandNames = map[code.Opcode][]string{
+	code.OpConstant:      {"constants"},
+	code.OpJump:          {"instructions"},
+	code.OpJumpNotTruthy: {"instructions"},
+	code.OpGetGlobal:     {"globals"},
+	code.OpSetGlobal:     {"globals"},
+	code.OpGetLocal:      {"locals"},
+	code.OpSetLocal:      {"locals"},
+	code.OpGetFree:       {"free variables"},
+	code.OpArray:         {"array elements"},
+	code.OpHash:          {"hash pairs"},
+	code.OpCall:          {"arguments"},
+	code.OpClosure:       {"constants", "free variables"},
+}
+
+var prefixOperators = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+	"~": code.OpBitNot,
+}
+
+func New() *Compiler {
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{{instructions: code.Instructions{}}},
+	}
+}
+
+// NewWithState creates a compiler that keeps the globals and constants of
+// previous compilations, so the REPL can compile line by line
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// Compile translates node and appends it to the bytecode, it fails when the
+// program needs more constants, globals, locals... than the VM can address
+func (c *Compiler) Compile(node ast.Node) error {
+	if err := c.compile(node); err != nil {
+		return err
+	}
+	return c.err
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	switch node := node.(type) {
+	// statements
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		return c.compileBlockStatement(node)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.BadStatement:
+		return fmt.Errorf("cannot compile bad statement at %s", node.Pos())
+	case *ast.LetStatement:
+		// the value is compiled before the name is bound, so it still sees
+		// any outer binding of the same name, as in the evaluator
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol := c.symbolTable.Define(node.Name.Value)
+		switch symbol.Scope {
+		case GlobalScope:
+			c.emit(code.OpSetGlobal, symbol.Index)
+		case CellScope:
+			c.emit(code.OpSetCell, symbol.Index)
+		default:
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+		c.symbolTable.markBound(node.Name.Value)
+
+	// expressions
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.Identifier:
+		c.compileIdentifier(node.Value)
+	case *ast.PrefixExpression:
+		op, ok := prefixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			if err := c.Compile(e); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		// each key is checked before its value runs, so an unusable key
+		// is reported ahead of any error in the value
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			c.emit(code.OpHashKey)
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.GlobalNames(),
+	}
+}
+
+// compileBlockStatement leaves exactly one value on the stack: the OpPop
+// after a final expression statement is removed, any other block pushes
+// OpNull after its statements
+func (c *Compiler) compileBlockStatement(block *ast.BlockStatement) error {
+	for _, s := range block.Statements {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+
+	if endsInExpression(block) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// the jump offsets are patched once the branches are compiled
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	// lets in the branches might not run
+	c.symbolTable.conditional++
+	defer func() { c.symbolTable.conditional-- }()
+
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.Compile(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// compileLogicalExpression jumps over the right operand when the left one
+// settles the result, to OpFalse for `&&` and to OpTrue for `||`. The
+// operand that decides is tested with OpJumpNotTruthy, so the expression
+// pushes OpTrue or OpFalse rather than the operand itself.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	var jumpsToFalse, jumpsToEnd []int
+
+	if node.Operator == "&&" {
+		jumpsToFalse = append(jumpsToFalse, c.emit(code.OpJumpNotTruthy, 9999))
+	} else {
+		jumpToRight := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpTrue)
+		jumpsToEnd = append(jumpsToEnd, c.emit(code.OpJump, 9999))
+		c.changeOperand(jumpToRight, len(c.currentInstructions()))
+	}
+
+	c.symbolTable.conditional++
+	err := c.Compile(node.Right)
+	c.symbolTable.conditional--
+	if err != nil {
+		return err
+	}
+
+	jumpsToFalse = append(jumpsToFalse, c.emit(code.OpJumpNotTruthy, 9999))
+	c.emit(code.OpTrue)
+	jumpsToEnd = append(jumpsToEnd, c.emit(code.OpJump, 9999))
+
+	for _, pos := range jumpsToFalse {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpFalse)
+
+	for _, pos := range jumpsToEnd {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+	c.symbolTable.functionName = node.Name
+
+	// all the lets of the body are declared up front, closures see the
+	// bindings made after they were created, and the locals they capture
+	// are cells so they share them
+	captured := capturedNames(node.Body)
+	for _, p := range node.Parameters {
+		c.defineLocal(p.Value, captured)
+		c.symbolTable.markBound(p.Value)
+	}
+	for _, name := range declaredNames(node.Body) {
+		c.defineLocal(name, captured)
+	}
+
+	for _, s := range node.Body.Statements {
+		if err := c.Compile(s); err != nil {
+			c.leaveScope()
+			return err
+		}
+	}
+
+	switch {
+	case endsInExpression(node.Body):
+		c.replaceLastPopWithReturn()
+	case !endsInReturn(node.Body):
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	instructions := c.leaveScope()
+
+	// even if never used every local needs a slot addressable by OpGetLocal
+	if numLocals > 1<<8 {
+		c.fail(errors.New("too many locals"))
+	}
+
+	for _, s := range freeSymbols {
+		c.loadCell(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Source:        object.FunctionSource(node.Parameters, node.Body),
+	}
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+
+	return nil
+}
+
+func endsInExpression(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+func endsInReturn(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ReturnStatement)
+	return ok
+}
+
+// defineLocal binds a name of the function being compiled, a new cell is
+// made for it when closures capture it
+func (c *Compiler) defineLocal(name string, captured map[string]bool) {
+	if !captured[name] {
+		c.symbolTable.Define(name)
+		return
+	}
+
+	numDefinitions := c.symbolTable.NumDefinitions()
+	symbol := c.symbolTable.DefineCell(name)
+	if c.symbolTable.NumDefinitions() > numDefinitions {
+		c.emit(code.OpMakeCell, symbol.Index)
+	}
+}
+
+// declaredNames are the names bound by the lets of a function body, the
+// ones in nested functions belong to those
+func declaredNames(body *ast.BlockStatement) []string {
+	names := []string{}
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			names = append(names, node.Name.Value)
+		}
+		return true
+	})
+	return names
+}
+
+// capturedNames are the names used by the functions nested in a function
+// body, any local among them has to be a cell
+func capturedNames(body *ast.BlockStatement) map[string]bool {
+	names := map[string]bool{}
+	ast.Inspect(body, func(node ast.Node) bool {
+		fn, ok := node.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+		ast.Inspect(fn, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Identifier); ok {
+				names[ident.Value] = true
+			}
+			return true
+		})
+		return false
+	})
+	return names
+}
+
+// compileIdentifier loads the innermost binding of name that is set when
+// the code runs, as the evaluator looks names up. A local that might not be
+// bound yet, its let still to run or in a branch that did not, falls back
+// to the next binding outwards.
+func (c *Compiler) compileIdentifier(name string) {
+	var jumps []int
+
+	for table := c.symbolTable; ; {
+		symbol, owner, ok := table.lookup(name)
+		if !ok {
+			// it may still be bound by a later let, if not the VM reports it
+			symbol, owner = c.symbolTable.DefineGlobal(name), c.symbolTable.global()
+		}
+
+		c.loadSymbol(c.symbolTable.capture(owner, symbol))
+		if c.symbolTable.isBound(owner, name) {
+			break
+		}
+
+		jumps = append(jumps, c.emit(code.OpJumpBound, 9999))
+		table = owner.Outer
+	}
+
+	for _, pos := range jumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case CellScope:
+		c.emit(code.OpGetCell, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+// loadCell pushes the cell of a captured variable to make a closure
+func (c *Compiler) loadCell(s Symbol) {
+	switch s.Scope {
+	case CellScope:
+		// the slot holds the cell itself
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFreeCell, s.Index)
+	default:
+		c.fail(fmt.Errorf("cannot capture %s, it is not a cell", s.Name))
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit appends an instruction to the current scope and returns its position,
+// an operand too large for its width is kept as the compilation error
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	for i, o := range operands {
+		if o < 1<<(8*def.OperandWidths[i]) {
+			continue
+		}
+		if names := operandNames[op]; i < len(names) {
+			c.fail(fmt.Errorf("too many %s", names[i]))
+		} else {
+			c.fail(fmt.Errorf("operand %d of %s out of range: %d", i, def.Name, o))
+		}
+	}
+}
+
+func (c *Compiler) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	pos := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return pos
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.checkOperands(op, []int{operand})
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{instructions: code.Instructions{}})
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
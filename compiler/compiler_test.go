@@ -0,0 +1,312 @@
+package compiler
+
+import (
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/code"
+	"cprieto.com/monkey/lexer"
+	"cprieto.com/monkey/object"
+	"cprieto.com/monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input        string
+		constants    []interface{}
+		instructions []code.Instructions
+	}{
+		{
+			"1 + 2",
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"1 < 2",
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"if (true) { 10 }; 3333;",
+			[]interface{}{10, 3333},
+			[]code.Instructions{
+				code.Make(code.OpTrue),              // 0000
+				code.Make(code.OpJumpNotTruthy, 10), // 0001
+				code.Make(code.OpConstant, 0),       // 0004
+				code.Make(code.OpJump, 11),          // 0007
+				code.Make(code.OpNull),              // 0010
+				code.Make(code.OpPop),               // 0011
+				code.Make(code.OpConstant, 1),       // 0012
+				code.Make(code.OpPop),               // 0015
+			},
+		},
+		{
+			"a && b",
+			[]interface{}{},
+			[]code.Instructions{
+				code.Make(code.OpGetGlobal, 0),      // 0000
+				code.Make(code.OpJumpNotTruthy, 16), // 0003
+				code.Make(code.OpGetGlobal, 1),      // 0006
+				code.Make(code.OpJumpNotTruthy, 16), // 0009
+				code.Make(code.OpTrue),              // 0012
+				code.Make(code.OpJump, 17),          // 0013
+				code.Make(code.OpFalse),             // 0016
+				code.Make(code.OpPop),               // 0017
+			},
+		},
+		{
+			"let one = 1; let one = 2; one;",
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			`{"a": [1]}["a"]`,
+			[]interface{}{"a", 1, "a"},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpHashKey),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"fn(a) { let b = a; }",
+			[]interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpReturn),
+				},
+			},
+			[]code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"let f = fn(a) { fn() { a + f() } }",
+			[]interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetGlobal, 0),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpMakeCell, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			[]code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			"let countDown = fn(x) { countDown(x - 1) }",
+			[]interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetGlobal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSub),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			[]code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			"fn() { let g = fn() { x }; let x = 1; g() }",
+			[]interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpJumpBound, 8),
+					code.Make(code.OpGetGlobal, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				[]code.Instructions{
+					code.Make(code.OpMakeCell, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSetCell, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			[]code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("Compile error not expected: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+		testInstructions(t, tt.input, tt.instructions, bytecode.Instructions)
+
+		if len(bytecode.Constants) != len(tt.constants) {
+			t.Fatalf("%s: expected %d constants but got %d", tt.input, len(tt.constants), len(bytecode.Constants))
+		}
+
+		for i, constant := range tt.constants {
+			testConstant(t, tt.input, constant, bytecode.Constants[i])
+		}
+	}
+}
+
+func testInstructions(t *testing.T, input string, expected []code.Instructions, actual code.Instructions) {
+	t.Helper()
+
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != actual.String() {
+		t.Errorf("%s: wrong instructions, expected\n%s\nbut got\n%s", input, concatted, actual)
+	}
+}
+
+func testConstant(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		if integer, ok := actual.(*object.Integer); !ok || integer.Value != int64(expected) {
+			t.Errorf("%s: expected constant %d but got %s", input, expected, actual.Inspect())
+		}
+	case string:
+		if str, ok := actual.(*object.String); !ok || str.Value != expected {
+			t.Errorf("%s: expected constant %q but got %s", input, expected, actual.Inspect())
+		}
+	case []code.Instructions:
+		fn, ok := actual.(*object.CompiledFunction)
+		if !ok {
+			t.Errorf("%s: expected a compiled function but got %T", input, actual)
+			return
+		}
+		testInstructions(t, input, expected, fn.Instructions)
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	// repeat joins n copies of format, %s being a distinct name each time
+	repeat := func(n int, format, sep string) string {
+		parts := make([]string, n)
+		for i := range parts {
+			name := ""
+			for j := i; j > 0 || name == ""; j /= 26 {
+				name = string(rune('a'+j%26)) + name
+			}
+			parts[i] = strings.ReplaceAll(format, "%s", name)
+		}
+		return strings.Join(parts, sep)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"constants", repeat(65536, "1", "\n"), ""},
+		{"constants", repeat(65537, "1", "\n"), "too many constants"},
+		{"globals", repeat(65536, "let x%s = true", "\n"), ""},
+		{"globals", repeat(65537, "let x%s = true", "\n"), "too many globals"},
+		{"locals", "fn() { " + repeat(256, "let x%s = true", "\n") + " }", ""},
+		{"locals", "fn() { " + repeat(257, "let x%s = true", "\n") + " }", "too many locals"},
+		{"parameters", "fn(" + repeat(257, "x%s", ", ") + ") { }", "too many locals"},
+		{"arguments", "f(" + repeat(255, "true", ", ") + ")", ""},
+		{"arguments", "f(" + repeat(256, "true", ", ") + ")", "too many arguments"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		err := New().Compile(program)
+		switch {
+		case tt.expected == "" && err != nil:
+			t.Errorf("%s: expected no error but got `%s`", tt.name, err)
+		case tt.expected != "" && (err == nil || err.Error() != tt.expected):
+			t.Errorf("%s: expected error `%s` but got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestCompileErrorLeavesScope(t *testing.T) {
+	symbolTable := NewSymbolTable()
+	compiler := NewWithState(symbolTable, []object.Object{})
+
+	// the parser never makes an unknown operator, so the tree is built by hand
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.ExpressionStatement{Expression: &ast.FunctionLiteral{
+			Parameters: []*ast.Identifier{},
+			Body: &ast.BlockStatement{Statements: []ast.Statement{
+				&ast.ExpressionStatement{Expression: &ast.PrefixExpression{
+					Operator: "?",
+					Right:    &ast.Boolean{Value: true},
+				}},
+			}},
+		}},
+	}}
+
+	if err := compiler.Compile(program); err == nil || err.Error() != "unknown operator ?" {
+		t.Fatalf("Expected error `unknown operator ?` but got %v", err)
+	}
+
+	if compiler.scopeIndex != 0 || len(compiler.scopes) != 1 {
+		t.Errorf("Expected to be back in the main scope but scope index is %d of %d", compiler.scopeIndex, len(compiler.scopes))
+	}
+	if compiler.symbolTable != symbolTable {
+		t.Errorf("Expected the global symbol table to be current again")
+	}
+}
@@ -0,0 +1,160 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	CellScope   SymbolScope = "CELL" // a local shared with closures, its slot holds an *object.Cell
+	FreeScope   SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable maps names to storage slots, there is one table per function
+// being compiled chained to the table of the enclosing function
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	names          []string // names by slot, only kept for globals
+
+	// FreeSymbols are the symbols, as seen from the enclosing function, of
+	// the cells this function captures
+	FreeSymbols []Symbol
+
+	bound        map[string]bool // names surely bound at this point of the code
+	conditional  int             // depth of branches that might not run
+	functionName string          // name the function is bound to by its let
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol), bound: make(map[string]bool)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define binds name in this table. Defining a name again in the same scope
+// reuses its slot, like `let` rebinding a name in the evaluator environment.
+func (s *SymbolTable) Define(name string) Symbol {
+	if s.Outer == nil {
+		return s.define(name, GlobalScope)
+	}
+	return s.define(name, LocalScope)
+}
+
+// DefineCell binds name to a local that closures can capture
+func (s *SymbolTable) DefineCell(name string) Symbol {
+	return s.define(name, CellScope)
+}
+
+func (s *SymbolTable) define(name string, scope SymbolScope) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Scope: scope, Index: s.numDefinitions}
+	s.store[name] = symbol
+	s.numDefinitions++
+
+	if scope == GlobalScope {
+		s.names = append(s.names, name)
+	}
+
+	return symbol
+}
+
+// DefineGlobal binds name in the outermost table, it is used for names that
+// do not resolve yet so they can be defined later or fail at runtime
+func (s *SymbolTable) DefineGlobal(name string) Symbol {
+	return s.global().Define(name)
+}
+
+func (s *SymbolTable) global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
+
+// Resolve looks name up through the enclosing tables, cells of enclosing
+// functions become free variables of this one
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, owner, ok := s.lookup(name)
+	if !ok {
+		return symbol, false
+	}
+	return s.capture(owner, symbol), true
+}
+
+// lookup finds the innermost table defining name
+func (s *SymbolTable) lookup(name string) (Symbol, *SymbolTable, bool) {
+	for t := s; t != nil; t = t.Outer {
+		if symbol, ok := t.store[name]; ok {
+			return symbol, t, true
+		}
+	}
+	return Symbol{}, nil, false
+}
+
+// capture returns how symbol, defined in owner, is reached from this table.
+// Every function between owner and this one captures it as well.
+func (s *SymbolTable) capture(owner *SymbolTable, symbol Symbol) Symbol {
+	if s == owner || symbol.Scope == GlobalScope {
+		return symbol
+	}
+
+	outer := s.Outer.capture(owner, symbol)
+	for i, free := range s.FreeSymbols {
+		if free == outer {
+			return Symbol{Name: symbol.Name, Scope: FreeScope, Index: i}
+		}
+	}
+
+	s.FreeSymbols = append(s.FreeSymbols, outer)
+	return Symbol{Name: symbol.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+}
+
+// markBound records that name is bound from now on, unless the binding is
+// in a branch that might not run
+func (s *SymbolTable) markBound(name string) {
+	if s.conditional == 0 {
+		s.bound[name] = true
+	}
+}
+
+// isBound tells if name, defined in owner, is surely bound whenever code
+// compiled now in this table runs
+func (s *SymbolTable) isBound(owner *SymbolTable, name string) bool {
+	if owner.Outer == nil || owner.bound[name] {
+		return true
+	}
+
+	// a function only runs after the let binding its name
+	for t := s; t != owner; t = t.Outer {
+		if t.Outer == owner && t.functionName == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NumDefinitions is the number of slots used by this table
+func (s *SymbolTable) NumDefinitions() int {
+	return s.numDefinitions
+}
+
+// GlobalNames returns the global names indexed by slot
+func (s *SymbolTable) GlobalNames() []string {
+	return s.global().names
+}
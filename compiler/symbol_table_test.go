@@ -0,0 +1,90 @@
+package compiler
+
+import "testing"
+
+func TestResolveNestedScopes(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"b", Symbol{Name: "b", Scope: FreeScope, Index: 0}},
+		{"c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+	}
+
+	for _, tt := range tests {
+		result, ok := secondLocal.Resolve(tt.name)
+		if !ok {
+			t.Errorf("Name %s not resolvable", tt.name)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Expected %s to resolve to %+v but got %+v", tt.name, tt.expected, result)
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 1 || secondLocal.FreeSymbols[0].Scope != LocalScope {
+		t.Errorf("Expected b to be captured as a free local but got %+v", secondLocal.FreeSymbols)
+	}
+}
+
+func TestDefineReusesSlot(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	global.Define("b")
+
+	if again := global.Define("a"); again != a {
+		t.Errorf("Expected redefinition to reuse %+v but got %+v", a, again)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	if shadow := local.Define("a"); shadow.Scope != LocalScope {
+		t.Errorf("Expected a local shadowing the global but got %+v", shadow)
+	}
+	if cell := local.DefineCell("a"); cell.Scope != LocalScope {
+		t.Errorf("Expected redefinition to keep the local but got %+v", cell)
+	}
+
+	if c := local.DefineGlobal("c"); c != (Symbol{Name: "c", Scope: GlobalScope, Index: 2}) {
+		t.Errorf("Expected c to be reserved as global 2 but got %+v", c)
+	}
+
+	names := global.GlobalNames()
+	if len(names) != 3 || names[0] != "a" || names[1] != "b" || names[2] != "c" {
+		t.Errorf("Expected global names [a b c] but got %v", names)
+	}
+}
+
+func TestResolveCapturesThroughEnclosingFunctions(t *testing.T) {
+	global := NewSymbolTable()
+
+	outer := NewEnclosedSymbolTable(global)
+	outer.DefineCell("x")
+
+	middle := NewEnclosedSymbolTable(outer)
+	inner := NewEnclosedSymbolTable(middle)
+
+	x, ok := inner.Resolve("x")
+	if !ok || x != (Symbol{Name: "x", Scope: FreeScope, Index: 0}) {
+		t.Fatalf("Expected x to resolve as free 0 but got %+v", x)
+	}
+	if again, _ := inner.Resolve("x"); again != x {
+		t.Errorf("Expected resolving again to reuse %+v but got %+v", x, again)
+	}
+
+	if len(middle.FreeSymbols) != 1 || middle.FreeSymbols[0] != (Symbol{Name: "x", Scope: CellScope, Index: 0}) {
+		t.Errorf("Expected middle to capture the cell of x but got %+v", middle.FreeSymbols)
+	}
+	if len(inner.FreeSymbols) != 1 || inner.FreeSymbols[0] != (Symbol{Name: "x", Scope: FreeScope, Index: 0}) {
+		t.Errorf("Expected inner to capture the free x of middle but got %+v", inner.FreeSymbols)
+	}
+}
@@ -0,0 +1,241 @@
+// Package diff compares texts line by line and reports the changes in the
+// unified format.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const context = 3
+
+type opKind byte
+
+const (
+	equal opKind = iota
+	deleted
+	inserted
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns the changes turning oldText into newText in the unified format,
+// nothing when both are the same
+func Unified(oldName, newName string, oldText, newText []byte) []byte {
+	if bytes.Equal(oldText, newText) {
+		return nil
+	}
+
+	ops := edits(splitLines(oldText), splitLines(newText))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(ops); {
+		// find the next change and grow its hunk while changes are close
+		first := start
+		for first < len(ops) && ops[first].kind == equal {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != equal {
+				last = i
+			} else if i-last > 2*context {
+				break
+			}
+		}
+
+		from := first - context
+		if from < start {
+			from = start
+		}
+		to := last + context + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+		writeHunk(&out, ops, from, to)
+		start = to
+	}
+
+	return out.Bytes()
+}
+
+func writeHunk(out *bytes.Buffer, ops []op, from, to int) {
+	oldStart, newStart := 1, 1
+	for _, o := range ops[:from] {
+		if o.kind != inserted {
+			oldStart++
+		}
+		if o.kind != deleted {
+			newStart++
+		}
+	}
+
+	oldLen, newLen := 0, 0
+	for _, o := range ops[from:to] {
+		if o.kind != inserted {
+			oldLen++
+		}
+		if o.kind != deleted {
+			newLen++
+		}
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldLen), hunkRange(newStart, newLen))
+
+	for _, o := range ops[from:to] {
+		switch o.kind {
+		case equal:
+			out.WriteByte(' ')
+		case deleted:
+			out.WriteByte('-')
+		case inserted:
+			out.WriteByte('+')
+		}
+
+		out.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
+
+// splitLines keeps the line endings so a missing one at the end shows up
+func splitLines(text []byte) []string {
+	lines := strings.SplitAfter(string(text), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// edits finds a shortest script of deletions from a and insertions from b
+// with Myers' O(ND) algorithm, in its linear space form: the middle snake
+// of a shortest path splits the texts in two parts compared the same way
+func edits(a, b []string) []op {
+	d := &differ{a: a, b: b}
+	d.compare(0, len(a), 0, len(b))
+	return d.ops
+}
+
+type differ struct {
+	a, b []string
+	ops  []op
+}
+
+// compare adds the ops turning a[aLo:aHi] into b[bLo:bHi]
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, op{equal, d.a[aLo]})
+		aLo++
+		bLo++
+	}
+
+	suffix := aHi
+	for aHi > aLo && bHi > bLo && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		for _, line := range d.b[bLo:bHi] {
+			d.ops = append(d.ops, op{inserted, line})
+		}
+	case bLo == bHi:
+		for _, line := range d.a[aLo:aHi] {
+			d.ops = append(d.ops, op{deleted, line})
+		}
+	default:
+		// both ends differ so the path has two edits at least and each
+		// part left and right of the snake is shorter
+		x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		for _, line := range d.a[x:u] {
+			d.ops = append(d.ops, op{equal, line})
+		}
+		d.compare(u, aHi, v, bHi)
+	}
+
+	for _, line := range d.a[aHi:suffix] {
+		d.ops = append(d.ops, op{equal, line})
+	}
+}
+
+// middleSnake runs shortest paths from both ends of a[aLo:aHi] and
+// b[bLo:bHi] until they meet, and returns the snake where they do as the
+// run of equal lines a[x:u], b[y:v]. Paths are followed by diagonal,
+// k = x - y, keeping only the furthest x reached on each.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	forward := make([]int, 2*maxD+3)  // x from the start
+	backward := make([]int, 2*maxD+3) // x from the end, on reversed texts
+
+	for D := 0; D <= maxD; D++ {
+		for k := -D; k <= D; k += 2 {
+			x := furthest(forward, offset, k, D)
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			// the backward path on the same diagonal took D-1 edits
+			if r := delta - k; odd && r >= -(D-1) && r <= D-1 && x+backward[offset+r] >= n {
+				return aLo + startX, bLo + startY, aLo + x, bLo + y
+			}
+		}
+
+		for k := -D; k <= D; k += 2 {
+			x := furthest(backward, offset, k, D)
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			if f := delta - k; !odd && f >= -D && f <= D && x+forward[offset+f] >= n {
+				return aHi - x, bHi - y, aHi - startX, bHi - startY
+			}
+		}
+	}
+
+	panic("diff: paths did not meet")
+}
+
+// furthest is where a path with D edits starts on diagonal k, one step
+// down or right from the best path with D-1 edits on a neighbour diagonal
+func furthest(v []int, offset, k, D int) int {
+	if k == -D || (k != D && v[offset+k-1] < v[offset+k+1]) {
+		return v[offset+k+1]
+	}
+	return v[offset+k-1] + 1
+}
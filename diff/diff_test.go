@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		old      string
+		new      string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{
+			"a\nb\nc\n",
+			"a\nB\nc\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			"--- old\n+++ new\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			"x\n1\n2\n3\n4\n5\n6\n7\n8\ny\n",
+			"X\n1\n2\n3\n4\n5\n6\n7\n8\nY\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n-x\n+X\n 1\n 2\n 3\n@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-y\n+Y\n",
+		},
+		{
+			"a",
+			"a\n",
+			"--- old\n+++ new\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n",
+		},
+		{
+			"",
+			"a\n",
+			"--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		actual := string(Unified("old", "new", []byte(tt.old), []byte(tt.new)))
+		if actual != tt.expected {
+			t.Errorf("Wrong diff for %q and %q, expected\n%s\nbut got\n%s", tt.old, tt.new, tt.expected, actual)
+		}
+	}
+}
+
+func TestEditsRebuildBothTexts(t *testing.T) {
+	tests := []struct {
+		old    string
+		new    string
+		common int // lines in the longest common subsequence
+	}{
+		{"abcabba", "cbabac", 4},
+		{"abc", "xyz", 0},
+		{"aaaa", "aa", 2},
+		{"xaby", "abab", 2},
+		{"", "abc", 0},
+	}
+
+	for _, tt := range tests {
+		a, b := strings.Split(tt.old, ""), strings.Split(tt.new, "")
+		if tt.old == "" {
+			a = nil
+		}
+
+		var oldLines, newLines []string
+		common := 0
+		for _, o := range edits(a, b) {
+			if o.kind != inserted {
+				oldLines = append(oldLines, o.line)
+			}
+			if o.kind != deleted {
+				newLines = append(newLines, o.line)
+			}
+			if o.kind == equal {
+				common++
+			}
+		}
+
+		if strings.Join(oldLines, "") != tt.old || strings.Join(newLines, "") != tt.new {
+			t.Errorf("Edits of %q and %q rebuild %q and %q", tt.old, tt.new, strings.Join(oldLines, ""), strings.Join(newLines, ""))
+		}
+		if common != tt.common {
+			t.Errorf("Expected %d equal lines for %q and %q but got %d", tt.common, tt.old, tt.new, common)
+		}
+	}
+}
+
+func TestLargeRewrite(t *testing.T) {
+	var oldText, newText strings.Builder
+	for i := 0; i < 4000; i++ {
+		fmt.Fprintf(&oldText, "old %d\n", i)
+		fmt.Fprintf(&newText, "new %d\n", i)
+	}
+
+	actual := string(Unified("old", "new", []byte(oldText.String()), []byte(newText.String())))
+	if !strings.HasPrefix(actual, "--- old\n+++ new\n@@ -1,4000 +1,4000 @@\n-old 0\n") {
+		t.Errorf("Wrong diff start %q", actual[:60])
+	}
+	if strings.Count(actual, "\n") != 3+8000 {
+		t.Errorf("Expected 8000 changed lines but got %d lines", strings.Count(actual, "\n"))
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"cprieto.com/monkey/diff"
+	"cprieto.com/monkey/format"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runFmt is the `monkey fmt` command, it formats the files given or the
+// standard input and returns the exit status
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	showDiff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	write := flags.Bool("w", false, "write result to (source) file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey fmt [-d] [-w] [path ...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "cannot use -w with standard input")
+			return 2
+		}
+		if err := formatFile("<standard input>", os.Stdin, *showDiff, false); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	status := 0
+	for _, filename := range flags.Args() {
+		if err := formatPath(filename, *showDiff, *write); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+func formatPath(filename string, showDiff, write bool) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return formatFile(filename, f, showDiff, write)
+}
+
+func formatFile(filename string, in io.Reader, showDiff, write bool) error {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	formatted, err := format.Source(filename, src)
+	if err != nil {
+		return err
+	}
+
+	if showDiff {
+		os.Stdout.Write(diff.Unified(filename+".orig", filename, src, formatted))
+	}
+
+	if write {
+		if bytes.Equal(src, formatted) {
+			return nil
+		}
+
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filename, formatted, info.Mode().Perm())
+	}
+
+	if !showDiff {
+		_, err = os.Stdout.Write(formatted)
+	}
+	return err
+}
@@ -0,0 +1,519 @@
+// Package format prints Monkey programs in the canonical style: four space
+// indentation, one statement per line, blocks and lists kept on one line
+// only while they fit in the line width.
+package format
+
+import (
+	"bytes"
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/lexer"
+	"cprieto.com/monkey/object"
+	"cprieto.com/monkey/parser"
+	"cprieto.com/monkey/token"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	indentation = "    "
+	maxWidth    = 80
+)
+
+// Source formats a whole source file, comments are kept. The source must
+// parse without errors.
+func Source(filename string, src []byte) ([]byte, error) {
+	p := parser.New(lexer.NewFile(filename, string(src)))
+	program := p.ParseProgram()
+	if err := p.Errors().Err(); err != nil {
+		return nil, err
+	}
+
+	pr := newPrinter()
+	pr.scan(filename, string(src))
+	pr.program(program)
+
+	return pr.out.Bytes(), nil
+}
+
+// Node writes node in the canonical style, there are no comments to keep
+// as the tree does not hold them
+func Node(w io.Writer, node ast.Node) error {
+	pr := newPrinter()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		pr.program(node)
+	case *ast.BlockStatement:
+		pr.block(node)
+	case *ast.LetStatement, *ast.ReturnStatement, *ast.ExpressionStatement, *ast.BadStatement:
+		pr.statement(node, nil, false)
+	default:
+		pr.expression(node)
+	}
+
+	_, err := w.Write(pr.out.Bytes())
+	return err
+}
+
+type printer struct {
+	out    *bytes.Buffer
+	indent int
+
+	flat   bool // everything goes on one line
+	failed bool // the flat rendering was not possible
+
+	// only known when formatting source
+	comments    []token.Token       // comments not written yet
+	tokens      []token.Token       // every other token, EOF included
+	closers     map[int]token.Token // closing delimiter by opening offset
+	lastComment token.Position      // end of the last comment written
+}
+
+func newPrinter() *printer {
+	return &printer{out: &bytes.Buffer{}}
+}
+
+// scan reads the tokens and comments of the source, they place the comments
+// and the blank lines between statements
+func (p *printer) scan(filename, src string) {
+	l := lexer.NewFile(filename, src)
+	l.SetMode(lexer.ScanComments)
+
+	p.closers = make(map[int]token.Token)
+	var open []token.Token
+
+	for {
+		tok := l.NextToken()
+
+		switch tok.Type {
+		case token.COMMENT:
+			p.comments = append(p.comments, tok)
+			continue
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			open = append(open, tok)
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			if len(open) > 0 {
+				p.closers[open[len(open)-1].Start.Offset] = tok
+				open = open[:len(open)-1]
+			}
+		}
+
+		p.tokens = append(p.tokens, tok)
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+/// ** Output
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+}
+
+// newline starts a new indented line, blank adds an empty one before it
+func (p *printer) newline(blank bool) {
+	if p.out.Len() == 0 {
+		return
+	}
+
+	p.write("\n")
+	if blank {
+		p.write("\n")
+	}
+	p.write(strings.Repeat(indentation, p.indent))
+}
+
+func (p *printer) column() int {
+	out := p.out.Bytes()
+	return utf8.RuneCount(out[bytes.LastIndexByte(out, '\n')+1:])
+}
+
+func (p *printer) fits(s string) bool {
+	return p.column()+utf8.RuneCountInString(s) <= maxWidth
+}
+
+// flatten renders f on a single line without writing it, it fails when
+// something in there needs more than one line
+func (p *printer) flatten(f func()) (string, bool) {
+	out, flat, failed := p.out, p.flat, p.failed
+	p.out, p.flat, p.failed = &bytes.Buffer{}, true, false
+
+	f()
+	s, ok := p.out.String(), !p.failed
+
+	p.out, p.flat, p.failed = out, flat, failed
+	return s, ok
+}
+
+/// ** Comments
+
+// linebreak starts the line for the source at pos, writing first the
+// comments found before it
+func (p *printer) linebreak(pos token.Position, keepBlank bool) {
+	p.flush(pos, keepBlank)
+	p.newline(keepBlank && p.blankBefore(pos))
+}
+
+// flush writes the comments found before pos, a comment sharing its line
+// with the code before it stays at the end of the current line, the others
+// go on lines of their own
+func (p *printer) flush(pos token.Position, keepBlank bool) {
+	for len(p.comments) > 0 && p.comments[0].Start.Offset < pos.Offset {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if prev := p.tokenBefore(c.Start.Offset); prev != nil && prev.End.Line == c.Start.Line && p.out.Len() > 0 {
+			p.write(" " + c.Literal)
+		} else {
+			p.newline(keepBlank && p.blankBefore(c.Start))
+			p.write(c.Literal)
+		}
+
+		p.lastComment = c.End
+	}
+}
+
+// blankBefore tells if the source had an empty line right before pos
+func (p *printer) blankBefore(pos token.Position) bool {
+	line := 0
+	if prev := p.tokenBefore(pos.Offset); prev != nil {
+		line = prev.End.Line
+	}
+	if p.lastComment.Offset < pos.Offset && p.lastComment.Line > line {
+		line = p.lastComment.Line
+	}
+
+	return line > 0 && pos.Line > line+1
+}
+
+func (p *printer) tokenBefore(offset int) *token.Token {
+	i := sort.Search(len(p.tokens), func(i int) bool {
+		return p.tokens[i].Start.Offset >= offset
+	})
+	if i == 0 {
+		return nil
+	}
+	return &p.tokens[i-1]
+}
+
+func (p *printer) hasComments(from, to token.Position) bool {
+	for _, c := range p.comments {
+		if c.Start.Offset >= from.Offset && c.Start.Offset < to.Offset {
+			return true
+		}
+	}
+	return false
+}
+
+// closer is the position of the delimiter closing the one at open, an
+// offset of -1 when it is not known
+func (p *printer) closer(open token.Token) token.Position {
+	if tok, ok := p.closers[open.Start.Offset]; ok && p.tokens != nil {
+		return tok.Start
+	}
+	return token.Position{Offset: -1}
+}
+
+/// ** Statements
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements, false)
+
+	p.flush(token.Position{Offset: math.MaxInt}, true)
+	if p.out.Len() > 0 {
+		p.write("\n")
+	}
+}
+
+func (p *printer) statements(stmts []ast.Statement, inBlock bool) {
+	for i, s := range stmts {
+		p.linebreak(s.Pos(), i > 0 || !inBlock)
+
+		var next ast.Statement
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+		}
+		p.statement(s, next, inBlock)
+	}
+}
+
+func (p *printer) statement(s ast.Statement, next ast.Statement, inBlock bool) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.write("let " + s.Name.Value + " = ")
+		p.expression(s.Value)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(s.Value)
+		p.write(";")
+	case *ast.ExpressionStatement:
+		p.expression(s.Expression)
+		if needsSemicolon(s, next, inBlock) {
+			p.write(";")
+		}
+	default:
+		p.write(s.String())
+	}
+}
+
+// needsSemicolon leaves it out for the value at the end of a block and
+// after an if, unless the next statement would read as its continuation
+func needsSemicolon(s *ast.ExpressionStatement, next ast.Statement, inBlock bool) bool {
+	if next == nil && inBlock {
+		return false
+	}
+
+	if _, ok := s.Expression.(*ast.IfExpression); !ok {
+		return true
+	}
+
+	if next, ok := next.(*ast.ExpressionStatement); ok {
+		switch next.Token.Type {
+		case token.LPAREN, token.LBRACKET, token.MINUS:
+			return true
+		}
+	}
+	return false
+}
+
+// block writes its statements one per line, in flat mode only a block with
+// a single expression or return can be written
+func (p *printer) block(b *ast.BlockStatement) {
+	if p.flat {
+		switch {
+		case len(b.Statements) == 0:
+			p.write("{}")
+		case len(b.Statements) > 1:
+			p.failed = true
+		default:
+			switch s := b.Statements[0].(type) {
+			case *ast.ExpressionStatement, *ast.ReturnStatement:
+				p.write("{ ")
+				p.statement(s, nil, true)
+				p.write(" }")
+			default:
+				p.failed = true
+			}
+		}
+		return
+	}
+
+	end := p.closer(b.Token)
+	if len(b.Statements) == 0 && !p.hasComments(b.Token.Start, end) {
+		p.write("{}")
+		return
+	}
+
+	p.write("{")
+	p.indent++
+	p.statements(b.Statements, true)
+	p.flush(end, false)
+	p.indent--
+	p.newline(false)
+	p.write("}")
+}
+
+/// ** Expressions
+
+func (p *printer) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		if e.Token.Literal != "" {
+			p.write(e.Token.Literal)
+		} else {
+			p.write(strconv.FormatInt(e.Value, 10))
+		}
+	case *ast.FloatLiteral:
+		if e.Token.Literal != "" {
+			p.write(e.Token.Literal)
+		} else {
+			// the lexer would read `2` as an integer
+			p.write(object.FormatFloat(e.Value))
+		}
+	case *ast.Boolean:
+		p.write(strconv.FormatBool(e.Value))
+	case *ast.StringLiteral:
+		p.write(ast.QuoteString(e.Value))
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.operand(e.Right, parser.PREFIX, false)
+	case *ast.InfixExpression:
+		prec := parser.Precedence(e.Operator)
+		p.operand(e.Left, prec, false)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, prec, true)
+	case *ast.IfExpression:
+		p.ifExpression(e)
+	case *ast.FunctionLiteral:
+		p.functionLiteral(e)
+	case *ast.CallExpression:
+		p.operand(e.Function, parser.CALL, false)
+		p.list(e.Token, "(", ")", len(e.Arguments), func(i int) {
+			p.expression(e.Arguments[i])
+		}, func(i int) token.Position {
+			return e.Arguments[i].Pos()
+		}, endsInFunction(e.Arguments))
+	case *ast.IndexExpression:
+		p.operand(e.Left, parser.INDEX, false)
+		p.write("[")
+		p.expression(e.Index)
+		p.write("]")
+	case *ast.ArrayLiteral:
+		p.list(e.Token, "[", "]", len(e.Elements), func(i int) {
+			p.expression(e.Elements[i])
+		}, func(i int) token.Position {
+			return e.Elements[i].Pos()
+		}, endsInFunction(e.Elements))
+	case *ast.HashLiteral:
+		p.list(e.Token, "{", "}", len(e.Pairs), func(i int) {
+			p.expression(e.Pairs[i].Key)
+			p.write(": ")
+			p.expression(e.Pairs[i].Value)
+		}, func(i int) token.Position {
+			return e.Pairs[i].Key.Pos()
+		}, false)
+	default:
+		if e != nil {
+			p.write(e.String())
+		}
+	}
+}
+
+// operand writes e as an operand of something binding as tight as prec,
+// the grouping parentheses the tree dropped are put back where needed
+func (p *printer) operand(e ast.Expression, prec int, right bool) {
+	parens := false
+
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		inner := parser.Precedence(e.Operator)
+		parens = inner < prec || (right && inner == prec)
+	case *ast.PrefixExpression:
+		parens = prec > parser.PREFIX
+	}
+
+	if parens {
+		p.write("(")
+	}
+	p.expression(e)
+	if parens {
+		p.write(")")
+	}
+}
+
+func (p *printer) ifExpression(e *ast.IfExpression) {
+	last := e.Consequence
+	if e.Alternative != nil {
+		last = e.Alternative
+	}
+
+	p.oneLineOr(e.Token.Start, p.closer(last.Token), func() {
+		p.write("if (")
+		p.expression(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence)
+
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	})
+}
+
+func (p *printer) functionLiteral(e *ast.FunctionLiteral) {
+	p.oneLineOr(e.Token.Start, p.closer(e.Body.Token), func() {
+		params := []string{}
+		for _, param := range e.Parameters {
+			params = append(params, param.Value)
+		}
+
+		p.write("fn(" + strings.Join(params, ", ") + ") ")
+		p.block(e.Body)
+	})
+}
+
+// oneLineOr writes f on a single line if it fits and there are no comments
+// between from and to, otherwise f writes its blocks over several lines
+func (p *printer) oneLineOr(from, to token.Position, f func()) {
+	if !p.flat && !p.hasComments(from, to) {
+		if s, ok := p.flatten(f); ok && p.fits(s) {
+			p.write(s)
+			return
+		}
+	}
+	f()
+}
+
+// list writes n items between open and close, on a single line if they fit
+// or one per line. With hug the last item, a function, is allowed to span
+// several lines right after the others.
+func (p *printer) list(opener token.Token, open, close string, n int, item func(int), pos func(int) token.Position, hug bool) {
+	oneLine := func() {
+		p.write(open)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				p.write(", ")
+			}
+			item(i)
+		}
+		p.write(close)
+	}
+
+	if p.flat {
+		oneLine()
+		return
+	}
+
+	end := p.closer(opener)
+	if !p.hasComments(opener.Start, end) {
+		if s, ok := p.flatten(oneLine); ok && p.fits(s) {
+			p.write(s)
+			return
+		}
+	}
+
+	if hug && !p.hasComments(opener.Start, pos(n-1)) {
+		head, ok := p.flatten(func() {
+			for i := 0; i < n-1; i++ {
+				item(i)
+				p.write(", ")
+			}
+		})
+		if ok && p.fits(open+head+"fn() {") {
+			p.write(open + head)
+			item(n - 1)
+			p.write(close)
+			return
+		}
+	}
+
+	p.write(open)
+	p.indent++
+	for i := 0; i < n; i++ {
+		p.linebreak(pos(i), false)
+		item(i)
+		if i < n-1 {
+			p.write(",")
+		}
+	}
+	p.flush(end, false)
+	p.indent--
+	p.newline(false)
+	p.write(close)
+}
+
+func endsInFunction(exps []ast.Expression) bool {
+	if len(exps) == 0 {
+		return false
+	}
+	_, ok := exps[len(exps)-1].(*ast.FunctionLiteral)
+	return ok
+}
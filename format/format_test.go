@@ -0,0 +1,176 @@
+package format
+
+import (
+	"bytes"
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/lexer"
+	"cprieto.com/monkey/parser"
+	"math"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"let add = fn(a,b){a+b}", "let add = fn(a, b) { a + b };\n"},
+		{"if (x) then { 1 } else { 2 }", "if (x) { 1 } else { 2 }\n"},
+		{"fn() { }", "fn() {};\n"},
+		{"(1 + 2) * 3; 1 + (2 * 3); 1 - (2 - 3); (1 - 2) - 3", "(1 + 2) * 3;\n1 + 2 * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n"},
+		{"-(a + b); !-a; (-f)(1); (a + b)[0]", "-(a + b);\n!-a;\n(-f)(1);\n(a + b)[0];\n"},
+		{`"say \"hi\"\n"`, "\"say \\\"hi\\\"\\n\";\n"},
+		{"0xFF + 1_000 + 2.50", "0xFF + 1_000 + 2.50;\n"},
+		{"if (x) { 1 }; -1", "if (x) { 1 };\n-1;\n"},
+		{"if (x) { 1 } let y = 2", "if (x) { 1 }\nlet y = 2;\n"},
+		{
+			"let f = fn(x) { let y = x; y }",
+			"let f = fn(x) {\n    let y = x;\n    y\n};\n",
+		},
+		{
+			"each([1, 2, 3], fn(x) { let y = x * 2; puts(y) })",
+			"each([1, 2, 3], fn(x) {\n    let y = x * 2;\n    puts(y)\n});\n",
+		},
+		{
+			`{"alpha": 1, "beta": 2, "gamma": 3, "delta": 4, "epsilon": 5, "zeta": 6, "eta": 7}`,
+			"{\n    \"alpha\": 1,\n    \"beta\": 2,\n    \"gamma\": 3,\n    \"delta\": 4,\n    \"epsilon\": 5,\n    \"zeta\": 6,\n    \"eta\": 7\n};\n",
+		},
+		{"let a = 1;\n\n\n\nlet b = 2;", "let a = 1;\n\nlet b = 2;\n"},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source("", []byte(tt.input))
+		if err != nil {
+			t.Fatalf("Formatting error not expected: %s", err)
+		}
+
+		if string(formatted) != tt.expected {
+			t.Errorf("Wrong format for %q, expected\n%s\nbut got\n%s", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	input := `// header
+
+let x = 5 // five
+/* about f */
+let f = fn() {
+  // nothing yet
+};
+let g = fn(a) { // trailing brace
+  a // the value
+}
+// footer`
+
+	expected := `// header
+
+let x = 5; // five
+/* about f */
+let f = fn() {
+    // nothing yet
+};
+let g = fn(a) { // trailing brace
+    a // the value
+};
+// footer
+`
+
+	formatted, err := Source("", []byte(input))
+	if err != nil {
+		t.Fatalf("Formatting error not expected: %s", err)
+	}
+
+	if string(formatted) != expected {
+		t.Errorf("Wrong format, expected\n%s\nbut got\n%s", expected, formatted)
+	}
+}
+
+// TestSourceStable checks that formatting keeps the meaning of the program
+// and that formatted code is left as it is
+func TestSourceStable(t *testing.T) {
+	inputs := []string{
+		"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)",
+		"let map = fn(arr, f) { let iter = fn(arr, acc) { if (len(arr) == 0) { acc } else { iter(rest(arr), push(acc, f(first(arr)))) } }; iter(arr, []) };",
+		"a && b || !c && (d || e); x << 2 & 1 | y ^ z; -x % 3 >= 2 == true",
+		"if (if (a) { b }) { c } (d); [1, fn(x) { x }(2), {true: [3]}[true][0]]",
+		`{"key": fn(a, b) { let c = a + b; return c * 2; }, "other": if (x) { 1 } else { let y = 2; y }}`,
+		"let f = fn() { return; }; fn() { }(); 1.5e3 * 2",
+	}
+
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			continue // `return;` is not valid, skip inputs the parser refuses
+		}
+
+		formatted, err := Source("", []byte(input))
+		if err != nil {
+			t.Fatalf("Formatting error not expected: %s", err)
+		}
+
+		p = parser.New(lexer.New(string(formatted)))
+		reparsed := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected for\n%s\n%s", formatted, p.Errors()[0])
+		}
+
+		if reparsed.String() != program.String() {
+			t.Errorf("Formatting changed the program, expected\n%s\nbut got\n%s", program, reparsed)
+		}
+
+		again, _ := Source("", formatted)
+		if !bytes.Equal(again, formatted) {
+			t.Errorf("Formatting is not stable, expected\n%s\nbut got\n%s", formatted, again)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	if _, err := Source("bad.mk", []byte("let = 5;")); err == nil {
+		t.Errorf("Expected a parsing error but got none")
+	}
+}
+
+func TestNode(t *testing.T) {
+	p := parser.New(lexer.New("let add = fn(a, b) { a + b } // gone"))
+	program := p.ParseProgram()
+
+	var out bytes.Buffer
+	if err := Node(&out, program.Statements[0]); err != nil {
+		t.Fatalf("Formatting error not expected: %s", err)
+	}
+
+	expected := "let add = fn(a, b) { a + b };"
+	if out.String() != expected {
+		t.Errorf("Expected `%s` but got `%s`", expected, out.String())
+	}
+}
+
+// literals built by hand have no token, they print like the REPL shows them
+func TestNodeFloatWithoutToken(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{2, "2.0"},
+		{0.5, "0.5"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := Node(&out, &ast.FloatLiteral{Value: tt.value}); err != nil {
+			t.Fatalf("Formatting error not expected: %s", err)
+		}
+
+		if out.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, out.String())
+		}
+	}
+}
@@ -1,36 +1,90 @@
 package lexer
 
-import "cprieto.com/monkey/token"
+import (
+	"cprieto.com/monkey/token"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Error is a problem found while lexing, the offending source is returned
+// as an ILLEGAL token at the same position
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Mode controls optional lexer behaviour
+type Mode uint
+
+const (
+	// ScanComments returns comments as COMMENT tokens instead of skipping them
+	ScanComments Mode = 1 << iota
+)
 
 type Lexer struct {
+	mode     Mode
+	filename string
 	input    string
-	position int
-	current  int
-	char     byte
+	position int   // offset of char
+	current  int   // offset right after char
+	char     rune  // utf8.RuneError on invalid encoding
+	column   int   // of char, counted in runes
+	lines    []int // offsets where each line starts
+	errors   []*Error
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer whose token positions carry the given file name
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, column: 1, lines: []int{0}}
 	l.readChar() // feed the first reading character
 
 	return l
 }
 
+// Errors returns the errors found so far, in the order they were found
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+// SetMode changes the lexer mode for the tokens still to be read
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	for {
+		l.skipWhitespace()
+
+		start := l.pos(l.position)
+		tok := l.scanToken()
+		tok.Start = start
+		tok.End = l.pos(l.position)
+
+		if tok.Type == token.COMMENT && l.mode&ScanComments == 0 {
+			continue
+		}
+		return tok
+	}
+}
 
-	l.skipWhitespace()
+func (l *Lexer) scanToken() token.Token {
+	var tok token.Token
 
 	switch l.char {
 	case '=':
-		if l.peekChar() == '=' {
-			ch := l.char
-			l.readChar()
-			literal := string(ch) + string(l.char)
-			tok = token.Token{Type: token.EQ, Literal: literal}
-		} else {
-			tok = newToken(token.ASSIGN, l.char)
-		}
+		tok = l.twoCharToken(token.ASSIGN, follow{'=', token.EQ})
 	case '+':
 		tok = newToken(token.PLUS, l.char)
 	case '-':
@@ -43,39 +97,75 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.char)
 	case '}':
 		tok = newToken(token.RBRACE, l.char)
+	case '[':
+		tok = newToken(token.LBRACKET, l.char)
+	case ']':
+		tok = newToken(token.RBRACKET, l.char)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.char)
+	case ':':
+		tok = newToken(token.COLON, l.char)
 	case ',':
 		tok = newToken(token.COMMA, l.char)
 	case '!':
-		if l.peekChar() == '=' {
-			ch := l.char
-			l.readChar()
-			literal := string(ch) + string(l.char)
-			tok = token.Token{Type: token.NE, Literal: literal}
-		} else {
-			tok = newToken(token.BANG, l.char)
-		}
+		tok = l.twoCharToken(token.BANG, follow{'=', token.NE})
 	case '/':
-		tok = newToken(token.SLASH, l.char)
+		switch l.peekChar() {
+		case '/':
+			return token.Token{Type: token.COMMENT, Literal: l.readLineComment()}
+		case '*':
+			literal, ok := l.readBlockComment()
+			if ok {
+				tok = token.Token{Type: token.COMMENT, Literal: literal}
+			} else {
+				tok = token.Token{Type: token.ILLEGAL, Literal: literal}
+			}
+		default:
+			tok = newToken(token.SLASH, l.char)
+		}
 	case '*':
 		tok = newToken(token.ASTERISK, l.char)
+	case '%':
+		tok = newToken(token.PERCENT, l.char)
 	case '<':
-		tok = newToken(token.LT, l.char)
+		tok = l.twoCharToken(token.LT, follow{'=', token.LE}, follow{'<', token.SHL})
 	case '>':
-		tok = newToken(token.GT, l.char)
+		tok = l.twoCharToken(token.GT, follow{'=', token.GE}, follow{'>', token.SHR})
+	case '&':
+		tok = l.twoCharToken(token.AMPERSAND, follow{'&', token.AND})
+	case '|':
+		tok = l.twoCharToken(token.PIPE, follow{'|', token.OR})
+	case '^':
+		tok = newToken(token.CARET, l.char)
+	case '~':
+		tok = newToken(token.TILDE, l.char)
+	case '"':
+		literal, ok := l.readString()
+		if ok {
+			tok = token.Token{Type: token.STRING, Literal: literal}
+		} else {
+			tok = token.Token{Type: token.ILLEGAL, Literal: literal}
+		}
 	case 0:
-		tok = token.Token{Type: token.EOF, Literal: ""}
+		if l.position >= len(l.input) {
+			tok = token.Token{Type: token.EOF, Literal: ""}
+		} else {
+			l.error(l.position, "unexpected character %q", l.char)
+			tok = newToken(token.ILLEGAL, l.char)
+		}
 	default:
-		if isLetter(l.char) {
+		if l.invalidChar() {
+			l.error(l.position, "invalid UTF-8 encoding")
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.input[l.position:l.current]}
+		} else if isLetter(l.char) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.char) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
 			return tok
 		} else {
+			l.error(l.position, "unexpected character %q", l.char)
 			tok = newToken(token.ILLEGAL, l.char)
 		}
 	}
@@ -85,21 +175,70 @@ func (l *Lexer) NextToken() token.Token {
 }
 
 func (l *Lexer) readChar() {
+	// move the column past the char read before, if any
+	if l.position < l.current {
+		if l.char == '\n' {
+			l.column = 1
+		} else {
+			l.column++
+		}
+	}
+
 	if l.current >= len(l.input) {
 		l.char = 0 // set char to NUL
-	} else {
-		l.char = l.input[l.current]
+		l.position = len(l.input)
+		l.current = len(l.input)
+		return
 	}
+
+	r, width := utf8.DecodeRuneInString(l.input[l.current:])
+	l.char = r
 	l.position = l.current
-	l.current += 1
+	l.current += width
+
+	if l.char == '\n' {
+		l.lines = append(l.lines, l.current)
+	}
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.current >= len(l.input) {
-		return '0'
+// invalidChar reports whether char comes from a byte that is not valid
+// UTF-8, as opposed to a literal U+FFFD in the source
+func (l *Lexer) invalidChar() bool {
+	return l.char == utf8.RuneError && l.current-l.position == 1
+}
+
+func (l *Lexer) error(offset int, format string, a ...interface{}) {
+	l.errors = append(l.errors, &Error{Pos: l.pos(offset), Msg: fmt.Sprintf(format, a...)})
+}
+
+// pos translates a byte offset already read by the lexer into a position,
+// columns count runes. Offsets on the line of char are counted back from its
+// column so long lines are not scanned again for every token.
+func (l *Lexer) pos(offset int) token.Position {
+	line := sort.Search(len(l.lines), func(i int) bool { return l.lines[i] > offset })
+
+	var column int
+	if skipped := l.input[offset:l.position]; !strings.Contains(skipped, "\n") {
+		column = l.column - utf8.RuneCountInString(skipped)
 	} else {
-		return l.input[l.current]
+		column = utf8.RuneCountInString(l.input[l.lines[line-1]:offset]) + 1
+	}
+
+	return token.Position{
+		Filename: l.filename,
+		Offset:   offset,
+		Line:     line,
+		Column:   column,
+	}
+}
+
+func (l *Lexer) peekChar() rune {
+	if l.current >= len(l.input) {
+		return 0
 	}
+
+	r, _ := utf8.DecodeRuneInString(l.input[l.current:])
+	return r
 }
 
 // read a given identifier
@@ -111,12 +250,250 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[pos:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+var numberBases = map[rune]struct {
+	name    string
+	isDigit func(rune) bool
+}{
+	'x': {"hexadecimal", isHexDigit},
+	'b': {"binary", func(ch rune) bool { return ch == '0' || ch == '1' }},
+	'o': {"octal", func(ch rune) bool { return '0' <= ch && ch <= '7' }},
+}
+
+// readNumber reads a number literal and returns its type, INT or FLOAT, or
+// ILLEGAL when malformed. Integers are decimal, or hexadecimal, binary and
+// octal with a 0x, 0b or 0o prefix; floats are decimal with a fraction, an
+// exponent or both (`3.14`, `1e-9`). Underscores may separate digits. Every
+// letter, digit and underscore touching the literal is read as part of it,
+// so `0b102` or `12ab` are reported as malformed instead of split in two.
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	pos := l.position
-	for isDigit(l.char) {
+	prefixed := l.char == '0' && strings.ContainsRune("xXbBoO", l.peekChar())
+
+	for {
+		switch {
+		case isDigit(l.char) || isASCIILetter(l.char) || l.char == '_':
+		case !prefixed && l.char == '.' && isDigit(l.peekChar()) &&
+			!strings.ContainsAny(l.input[pos:l.position], ".eE"):
+		case !prefixed && (l.char == '+' || l.char == '-') &&
+			strings.ContainsRune("eE", rune(l.input[l.position-1])):
+		default:
+			literal := l.input[pos:l.position]
+			if prefixed {
+				return l.checkPrefixedNumber(pos, literal)
+			}
+			return l.checkDecimalNumber(pos, literal)
+		}
 		l.readChar()
 	}
-	return l.input[pos:l.position]
+}
+
+func (l *Lexer) checkPrefixedNumber(pos int, literal string) (token.TokenType, string) {
+	base := numberBases[unicode.ToLower(rune(literal[1]))]
+	digits := literal[2:]
+	// a separator is allowed right after the prefix, as in 0x_FF
+	if strings.HasPrefix(digits, "_") {
+		digits = digits[1:]
+	}
+	start := pos + len(literal) - len(digits)
+
+	if digits == "" {
+		l.error(pos, "%s literal has no digits", base.name)
+		return token.ILLEGAL, literal
+	}
+
+	for i, ch := range digits {
+		switch {
+		case ch == '_':
+			if i == len(digits)-1 || digits[i+1] == '_' {
+				l.error(start+i, "'_' must separate successive digits")
+				return token.ILLEGAL, literal
+			}
+		case !base.isDigit(ch):
+			l.error(start+i, "invalid digit %q in %s literal", ch, base.name)
+			return token.ILLEGAL, literal
+		}
+	}
+
+	return token.INT, literal
+}
+
+func (l *Lexer) checkDecimalNumber(pos int, literal string) (token.TokenType, string) {
+	var tokType token.TokenType = token.INT
+	name := "decimal"
+	if strings.ContainsAny(literal, ".eE") {
+		tokType, name = token.FLOAT, "float"
+	}
+
+	exponent := -1
+	for i := 0; i < len(literal); i++ {
+		ch := literal[i]
+		switch {
+		case isDigit(rune(ch)), ch == '.':
+		case ch == '_':
+			if !isDigit(rune(literal[i-1])) || i == len(literal)-1 || !isDigit(rune(literal[i+1])) {
+				l.error(pos+i, "'_' must separate successive digits")
+				return token.ILLEGAL, literal
+			}
+		case (ch == 'e' || ch == 'E') && exponent < 0:
+			exponent = i
+		case (ch == '+' || ch == '-') && i == exponent+1:
+		default:
+			l.error(pos+i, "invalid digit %q in %s literal", ch, name)
+			return token.ILLEGAL, literal
+		}
+	}
+
+	if exponent >= 0 && !strings.ContainsAny(literal[exponent:], "0123456789") {
+		l.error(pos+exponent, "exponent has no digits")
+		return token.ILLEGAL, literal
+	}
+
+	return tokType, literal
+}
+
+// follow is a token made of the current char followed by next
+type follow struct {
+	next      rune
+	tokenType token.TokenType
+}
+
+// twoCharToken looks ahead one char: when it matches one of the follows both
+// chars are read as that token, otherwise the current char is returned as a
+// single char token
+func (l *Lexer) twoCharToken(single token.TokenType, follows ...follow) token.Token {
+	for _, f := range follows {
+		if l.peekChar() == f.next {
+			ch := l.char
+			l.readChar()
+			return token.Token{Type: f.tokenType, Literal: string(ch) + string(l.char)}
+		}
+	}
+	return newToken(single, l.char)
+}
+
+// readLineComment reads a `//` comment up to, not including, the end of line
+func (l *Lexer) readLineComment() string {
+	pos := l.position
+	for l.char != '\n' && l.position < len(l.input) {
+		l.readChar()
+	}
+	return strings.TrimSuffix(l.input[pos:l.position], "\r")
+}
+
+// readBlockComment reads a `/* */` comment, block comments nest so every
+// `/*` inside needs its own `*/`. The lexer is left on the final `/`.
+func (l *Lexer) readBlockComment() (string, bool) {
+	pos := l.position
+	depth := 0
+
+	for l.position < len(l.input) {
+		switch {
+		case l.char == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.char == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				return l.input[pos:l.current], true
+			}
+		}
+		l.readChar()
+	}
+
+	l.error(pos, "unterminated block comment")
+	return l.input[pos:l.position], false
+}
+
+// readString reads a double quoted string and returns its unescaped value.
+// On an unterminated string or a bad escape it returns the raw source text
+// and false. The lexer is left on the closing quote.
+func (l *Lexer) readString() (string, bool) {
+	pos := l.position
+	valid := true
+	var out strings.Builder
+
+	for {
+		l.readChar()
+
+		switch l.char {
+		case '"':
+			if !valid {
+				return l.input[pos:l.current], false
+			}
+			return out.String(), true
+		case 0:
+			if l.position >= len(l.input) {
+				l.error(pos, "unterminated string literal")
+				return l.input[pos:l.position], false
+			}
+			out.WriteRune(l.char)
+		case '\\':
+			escape := l.position
+			switch l.peekChar() {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case '"':
+				out.WriteByte('"')
+			case '\\':
+				out.WriteByte('\\')
+			case 'u':
+				l.readChar()
+				r, ok := l.readUnicodeEscape()
+				if !ok {
+					l.error(escape, "invalid unicode escape")
+					valid = false
+				}
+				out.WriteRune(r)
+				continue
+			default:
+				// leave the next char alone, it may be the closing quote
+				l.error(escape, "invalid escape sequence")
+				valid = false
+				continue
+			}
+			l.readChar()
+		default:
+			if l.invalidChar() {
+				l.error(l.position, "invalid UTF-8 encoding")
+				valid = false
+			}
+			out.WriteRune(l.char)
+		}
+	}
+}
+
+// readUnicodeEscape reads the `{...}` part of a `\u{...}` escape, holding
+// one to six hex digits naming a valid code point
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	if l.peekChar() != '{' {
+		return 0, false
+	}
+	l.readChar()
+
+	pos := l.current
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[pos:l.current]
+
+	if l.peekChar() != '}' {
+		return 0, false
+	}
+	l.readChar()
+
+	if len(digits) == 0 || len(digits) > 6 {
+		return 0, false
+	}
+
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || value > 0x10FFFF || (value >= 0xD800 && value <= 0xDFFF) {
+		return 0, false
+	}
+
+	return rune(value), true
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -125,16 +502,24 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-// create a token from a given type and byte
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+// create a token from a given type and rune
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-// check if a byte is a letter
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// check if a rune is a letter, any Unicode letter is allowed
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func isDigit(ch byte) bool {
+func isASCIILetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
+}
+
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
@@ -2,11 +2,12 @@ package lexer
 
 import (
 	"cprieto.com/monkey/token"
+	"strings"
 	"testing"
 )
 
 func TestNextToken(t *testing.T) {
-	input := `=+(){}!*/<>`
+	input := `=+(){}[]:!*/<>`
 	tests := []struct {
 		expectedType    token.TokenType
 		expectedLiteral string
@@ -17,6 +18,9 @@ func TestNextToken(t *testing.T) {
 		{token.RPAREN, ")"},
 		{token.LBRACE, "{"},
 		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.RBRACKET, "]"},
+		{token.COLON, ":"},
 		{token.BANG, "!"},
 		{token.ASTERISK, "*"},
 		{token.SLASH, "/"},
@@ -181,3 +185,433 @@ func TestComplexEquality(t *testing.T) {
 		}
 	}
 }
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"foobar"`, token.STRING, "foobar"},
+		{`"foo bar"`, token.STRING, "foo bar"},
+		{`""`, token.STRING, ""},
+		{`"a\nb\tc"`, token.STRING, "a\nb\tc"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"\u{48}\u{49}"`, token.STRING, "HI"},
+		{`"\u{1F600}"`, token.STRING, "\U0001F600"},
+		{`"unterminated`, token.ILLEGAL, `"unterminated`},
+		{`"bad \q escape"`, token.ILLEGAL, `"bad \q escape"`},
+		{`"bad \u{110000}"`, token.ILLEGAL, `"bad \u{110000}"`},
+		{`"bad \u{}"`, token.ILLEGAL, `"bad \u{}"`},
+		{`"bad \u{48"`, token.ILLEGAL, `"bad \u{48"`},
+		{`"ends \"`, token.ILLEGAL, `"ends \"`},
+	}
+
+	for n, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test [%d] wrong token type, expected %q but got %q", n, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test [%d] wrong token literal, expected '%q' but got '%q'", n, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok = l.NextToken(); tok.Type != token.EOF {
+			t.Fatalf("test [%d] expected EOF after the string but got %q", n, tok.Type)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	pos := func(offset, line, column int) token.Position {
+		return token.Position{Filename: "main.mk", Offset: offset, Line: line, Column: column}
+	}
+
+	input := "let x = 5;\n  x +\n\"ab\""
+	tests := []struct {
+		expectedType  token.TokenType
+		expectedStart token.Position
+		expectedEnd   token.Position
+	}{
+		{token.LET, pos(0, 1, 1), pos(3, 1, 4)},
+		{token.IDENT, pos(4, 1, 5), pos(5, 1, 6)},
+		{token.ASSIGN, pos(6, 1, 7), pos(7, 1, 8)},
+		{token.INT, pos(8, 1, 9), pos(9, 1, 10)},
+		{token.SEMICOLON, pos(9, 1, 10), pos(10, 1, 11)},
+		{token.IDENT, pos(13, 2, 3), pos(14, 2, 4)},
+		{token.PLUS, pos(15, 2, 5), pos(16, 2, 6)},
+		{token.STRING, pos(17, 3, 1), pos(21, 3, 5)},
+		{token.EOF, pos(21, 3, 5), pos(21, 3, 5)},
+		{token.EOF, pos(21, 3, 5), pos(21, 3, 5)},
+	}
+
+	l := NewFile("main.mk", input)
+	for n, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test [%d] wrong token type, expected %q but got %q", n, tt.expectedType, tok.Type)
+		}
+
+		if tok.Start != tt.expectedStart {
+			t.Fatalf("test [%d] wrong start, expected %+v but got %+v", n, tt.expectedStart, tok.Start)
+		}
+
+		if tok.End != tt.expectedEnd {
+			t.Fatalf("test [%d] wrong end, expected %+v but got %+v", n, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestLongLinePositions(t *testing.T) {
+	// columns used to be counted from the start of the line for every
+	// token, this input took minutes to lex
+	const count = 200000
+	l := New("é" + strings.Repeat(" x", count) + "\n\"a\nb\\q\"")
+
+	l.NextToken()
+	for i := 0; i < count; i++ {
+		tok := l.NextToken()
+		if expected := 3 + 2*i; tok.Start.Column != expected {
+			t.Fatalf("token %d: expected column %d but got %d", i, expected, tok.Start.Column)
+		}
+	}
+
+	tok := l.NextToken()
+	if tok.Type != token.ILLEGAL || len(l.Errors()) != 1 {
+		t.Fatalf("Expected an invalid escape but got %q and errors %v", tok.Type, l.Errors())
+	}
+	if expected := "3:2: invalid escape sequence"; l.Errors()[0].Error() != expected {
+		t.Errorf("Expected error `%s` but got `%s`", expected, l.Errors()[0])
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos      token.Position
+		expected string
+	}{
+		{token.Position{Filename: "a.mk", Offset: 4, Line: 2, Column: 3}, "a.mk:2:3"},
+		{token.Position{Offset: 4, Line: 2, Column: 3}, "2:3"},
+		{token.Position{Filename: "a.mk"}, "a.mk"},
+		{token.Position{}, "-"},
+	}
+
+	for _, tt := range tests {
+		if tt.pos.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, tt.pos.String())
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := "let café = \"naïve 日本\"; größe + π"
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "café", 5},
+		{token.ASSIGN, "=", 10},
+		{token.STRING, "naïve 日本", 12},
+		{token.SEMICOLON, ";", 22},
+		{token.IDENT, "größe", 24},
+		{token.PLUS, "+", 30},
+		{token.IDENT, "π", 32},
+		{token.EOF, "", 33},
+	}
+
+	l := New(input)
+	for n, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test [%d] wrong token type, expected %q but got %q", n, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test [%d] wrong token literal, expected '%q' but got '%q'", n, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Start.Column != tt.expectedColumn {
+			t.Fatalf("test [%d] wrong column, expected %d but got %d", n, tt.expectedColumn, tok.Start.Column)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Fatalf("Lexing error not expected: %s", l.Errors()[0])
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+	}{
+		{"a \xff b", "\xff", "1:3: invalid UTF-8 encoding"},
+		{"\"ok \xfe\"", "\"ok \xfe\"", "1:5: invalid UTF-8 encoding"},
+		{"x @", "@", "1:3: unexpected character '@'"},
+		{"\n  \"open", "\"open", "2:3: unterminated string literal"},
+		{`"a\qb"`, `"a\qb"`, "1:3: invalid escape sequence"},
+		{`"é\u{zz}"`, `"é\u{zz}"`, "1:3: invalid unicode escape"},
+	}
+
+	for n, tt := range tests {
+		l := New(tt.input)
+
+		var illegal token.Token
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if tok.Type == token.ILLEGAL {
+				illegal = tok
+			}
+		}
+
+		if illegal.Literal != tt.expectedLiteral {
+			t.Errorf("test [%d] wrong illegal literal, expected %q but got %q", n, tt.expectedLiteral, illegal.Literal)
+		}
+
+		if len(l.Errors()) != 1 {
+			t.Fatalf("test [%d] expected 1 error but got %d", n, len(l.Errors()))
+		}
+
+		if l.Errors()[0].Error() != tt.expectedError {
+			t.Errorf("test [%d] expected error `%s` but got `%s`", n, tt.expectedError, l.Errors()[0])
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing
+/* block /* nested */ still comment */ x / 2
+/**/`
+
+	skipped := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for n, tt := range skipped {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test [%d] wrong token type, expected %q but got %q", n, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test [%d] wrong token literal, expected '%q' but got '%q'", n, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	scanned := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// leading comment"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing"},
+		{token.COMMENT, "/* block /* nested */ still comment */"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.COMMENT, "/**/"},
+		{token.EOF, ""},
+	}
+
+	l = New(input)
+	l.SetMode(ScanComments)
+	for n, tt := range scanned {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test [%d] wrong token type, expected %q but got %q", n, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test [%d] wrong token literal, expected '%q' but got '%q'", n, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("1 /* open /* nested */")
+
+	if tok := l.NextToken(); tok.Type != token.INT {
+		t.Fatalf("wrong token type, expected %q but got %q", token.INT, tok.Type)
+	}
+
+	tok := l.NextToken()
+	if tok.Type != token.ILLEGAL || tok.Literal != "/* open /* nested */" {
+		t.Fatalf("expected an ILLEGAL comment token but got %q '%q'", tok.Type, tok.Literal)
+	}
+
+	if len(l.Errors()) != 1 || l.Errors()[0].Error() != "1:3: unterminated block comment" {
+		t.Fatalf("expected an unterminated comment error but got %v", l.Errors())
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	valid := []string{"0", "42", "1_000_000", "0xFF", "0Xff", "0x_1F", "0b1010", "0B1_0", "0o17", "0O7_7", "007"}
+
+	for _, input := range valid {
+		l := New(input)
+		tok := l.NextToken()
+
+		if tok.Type != token.INT || tok.Literal != input {
+			t.Errorf("expected INT '%s' but got %q '%s'", input, tok.Type, tok.Literal)
+		}
+
+		if len(l.Errors()) != 0 {
+			t.Errorf("Lexing error not expected for '%s': %s", input, l.Errors()[0])
+		}
+	}
+
+	invalid := []struct {
+		input         string
+		expectedError string
+	}{
+		{"0x", "1:1: hexadecimal literal has no digits"},
+		{"0b_", "1:1: binary literal has no digits"},
+		{"1__0", "1:2: '_' must separate successive digits"},
+		{"10_", "1:3: '_' must separate successive digits"},
+		{"0x_", "1:1: hexadecimal literal has no digits"},
+		{"0b102", "1:5: invalid digit '2' in binary literal"},
+		{"0o8", "1:3: invalid digit '8' in octal literal"},
+		{"0xFG", "1:4: invalid digit 'G' in hexadecimal literal"},
+		{"12ab", "1:3: invalid digit 'a' in decimal literal"},
+	}
+
+	for _, tt := range invalid {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.ILLEGAL || tok.Literal != tt.input {
+			t.Errorf("expected ILLEGAL '%s' but got %q '%s'", tt.input, tok.Type, tok.Literal)
+		}
+
+		if len(l.Errors()) != 1 || l.Errors()[0].Error() != tt.expectedError {
+			t.Errorf("expected error `%s` but got %v", tt.expectedError, l.Errors())
+		}
+	}
+}
+
+func TestFloatLiterals(t *testing.T) {
+	valid := []string{"3.14", "0.5", "1e-9", "1E+10", "2.5e3", "1_000.000_1", "0e0", "10.0"}
+
+	for _, input := range valid {
+		l := New(input)
+		tok := l.NextToken()
+
+		if tok.Type != token.FLOAT || tok.Literal != input {
+			t.Errorf("expected FLOAT '%s' but got %q '%s'", input, tok.Type, tok.Literal)
+		}
+
+		if len(l.Errors()) != 0 {
+			t.Errorf("Lexing error not expected for '%s': %s", input, l.Errors()[0])
+		}
+	}
+
+	invalid := []struct {
+		input         string
+		expectedError string
+	}{
+		{"1e", "1:2: exponent has no digits"},
+		{"1.5e+", "1:4: exponent has no digits"},
+		{"1.5x", "1:4: invalid digit 'x' in float literal"},
+		{"1_.5", "1:2: '_' must separate successive digits"},
+		{"1e_5", "1:3: '_' must separate successive digits"},
+		{"1e5e5", "1:4: invalid digit 'e' in float literal"},
+	}
+
+	for _, tt := range invalid {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.ILLEGAL || tok.Literal != tt.input {
+			t.Errorf("expected ILLEGAL '%s' but got %q '%s'", tt.input, tok.Type, tok.Literal)
+		}
+
+		if len(l.Errors()) != 1 || l.Errors()[0].Error() != tt.expectedError {
+			t.Errorf("expected error `%s` for '%s' but got %v", tt.expectedError, tt.input, l.Errors())
+		}
+	}
+
+	l := New("1.5-2 3.x")
+	expected := []token.TokenType{token.FLOAT, token.MINUS, token.INT, token.INT, token.ILLEGAL, token.IDENT, token.EOF}
+	for n, tt := range expected {
+		if tok := l.NextToken(); tok.Type != tt {
+			t.Fatalf("test [%d] wrong token type, expected %q but got %q", n, tt, tok.Type)
+		}
+	}
+}
+
+func TestExtendedOperators(t *testing.T) {
+	input := `a <= b >= c % d && e || f & g | h ^ i << j >> k ~l < m > n`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LE, "<="},
+		{token.IDENT, "b"},
+		{token.GE, ">="},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "d"},
+		{token.AND, "&&"},
+		{token.IDENT, "e"},
+		{token.OR, "||"},
+		{token.IDENT, "f"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "g"},
+		{token.PIPE, "|"},
+		{token.IDENT, "h"},
+		{token.CARET, "^"},
+		{token.IDENT, "i"},
+		{token.SHL, "<<"},
+		{token.IDENT, "j"},
+		{token.SHR, ">>"},
+		{token.IDENT, "k"},
+		{token.TILDE, "~"},
+		{token.IDENT, "l"},
+		{token.LT, "<"},
+		{token.IDENT, "m"},
+		{token.GT, ">"},
+		{token.IDENT, "n"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for n, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test [%d] wrong token type, expected %q but got %q", n, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test [%d] wrong token literal, expected '%q' but got '%q'", n, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+// Package lineedit reads lines from a terminal letting them be edited as
+// they are typed, with the usual Emacs style keys:
+//
+//	Left, Ctrl-B / Right, Ctrl-F    move a character
+//	Alt-B / Alt-F                   move a word
+//	Home, Ctrl-A / End, Ctrl-E      move to the start / end of the line
+//	Backspace, Ctrl-H / Delete      delete before / under the cursor
+//	Ctrl-K / Ctrl-U                 kill to the end / start of the line
+//	Ctrl-W, Alt-Backspace / Alt-D   kill the previous / next word
+//	Ctrl-Y                          yank the last killed text
+//	Up, Ctrl-P / Down, Ctrl-N       recall the previous / next history line
+//	Ctrl-R                          search the history backwards
+//	Ctrl-L                          clear the screen
+//	Ctrl-C                          discard the line
+//	Ctrl-D                          delete under the cursor, end of input
+//	                                on an empty line
+//
+// The terminal is only in raw mode while a line is being read.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupted is returned by ReadLine when the line is discarded with
+// Ctrl-C
+var ErrInterrupted = errors.New("lineedit: interrupted")
+
+const defaultWidth = 80
+
+// keys that are not runes are negative
+const (
+	keyUnknown rune = -1 - iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+	keyKillWord
+	keyKillPrevWord
+)
+
+const (
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+func ctrl(r rune) rune {
+	return r & 0x1f
+}
+
+type Editor struct {
+	History *History
+
+	in     *bufio.Reader
+	out    io.Writer
+	fd     int // of the terminal, -1 when in is not one
+	yanked []rune
+}
+
+// New makes an editor reading keys from in and echoing to out, the
+// terminal is put in raw mode only when in is one
+func New(in io.Reader, out io.Writer) *Editor {
+	fd := -1
+	if f, ok := in.(*os.File); ok && IsTerminal(int(f.Fd())) {
+		fd = int(f.Fd())
+	}
+
+	return &Editor{
+		History: NewHistory(DefaultHistorySize),
+		in:      bufio.NewReader(in),
+		out:     out,
+		fd:      fd,
+	}
+}
+
+// line is the state of the line being edited
+type line struct {
+	prompt  string
+	buf     []rune
+	pos     int    // of the cursor in buf
+	index   int    // of the history entry shown, History.Len() for the new line
+	editing []rune // the new line while browsing the history
+}
+
+// ReadLine shows prompt and reads a line, which is returned without the
+// newline. At the end of the input it returns io.EOF, and ErrInterrupted
+// when the line is discarded.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		state, err := makeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restore(e.fd, state)
+	}
+
+	l := &line{prompt: prompt, index: e.History.Len()}
+	e.refresh(l)
+
+	for {
+		k, err := e.readKey()
+		if err == io.EOF && len(l.buf) > 0 {
+			e.write("\r\n")
+			return string(l.buf), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if k == ctrl('r') {
+			if k, err = e.search(l); err != nil {
+				return "", err
+			}
+			e.refresh(l)
+		}
+
+		switch k {
+		case ctrl('m'), ctrl('j'):
+			e.write("\r\n")
+			return string(l.buf), nil
+		case ctrl('c'):
+			e.write("^C\r\n")
+			return "", ErrInterrupted
+		case ctrl('d'):
+			if len(l.buf) == 0 {
+				e.write("\r\n")
+				return "", io.EOF
+			}
+			l.delete(l.pos, l.pos+1)
+		case ctrl('l'):
+			e.write("\x1b[H\x1b[2J")
+		default:
+			e.edit(l, k)
+		}
+
+		e.refresh(l)
+	}
+}
+
+// edit applies a key that does not end the line
+func (e *Editor) edit(l *line, k rune) {
+	switch k {
+	case keyLeft, ctrl('b'):
+		if l.pos > 0 {
+			l.pos--
+		}
+	case keyRight, ctrl('f'):
+		if l.pos < len(l.buf) {
+			l.pos++
+		}
+	case keyWordLeft:
+		l.pos = l.wordStart()
+	case keyWordRight:
+		l.pos = l.wordEnd()
+	case keyHome, ctrl('a'):
+		l.pos = 0
+	case keyEnd, ctrl('e'):
+		l.pos = len(l.buf)
+	case keyBackspace, ctrl('h'):
+		if l.pos > 0 {
+			l.delete(l.pos-1, l.pos)
+		}
+	case keyDelete:
+		l.delete(l.pos, l.pos+1)
+	case ctrl('k'):
+		e.kill(l, l.pos, len(l.buf))
+	case ctrl('u'):
+		e.kill(l, 0, l.pos)
+	case ctrl('w'):
+		start := l.pos
+		for start > 0 && unicode.IsSpace(l.buf[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(l.buf[start-1]) {
+			start--
+		}
+		e.kill(l, start, l.pos)
+	case keyKillPrevWord:
+		e.kill(l, l.wordStart(), l.pos)
+	case keyKillWord:
+		e.kill(l, l.pos, l.wordEnd())
+	case ctrl('y'):
+		l.insert(e.yanked...)
+	case keyUp, ctrl('p'):
+		e.recall(l, l.index-1)
+	case keyDown, ctrl('n'):
+		e.recall(l, l.index+1)
+	case ctrl('i'):
+		l.insert([]rune("    ")...)
+	default:
+		if k >= ' ' && unicode.IsPrint(k) {
+			l.insert(k)
+		}
+	}
+}
+
+func (l *line) insert(runes ...rune) {
+	buf := make([]rune, 0, len(l.buf)+len(runes))
+	buf = append(buf, l.buf[:l.pos]...)
+	buf = append(buf, runes...)
+	l.buf = append(buf, l.buf[l.pos:]...)
+	l.pos += len(runes)
+}
+
+// delete removes buf[start:end], end is clamped to the line
+func (l *line) delete(start, end int) []rune {
+	if end > len(l.buf) {
+		end = len(l.buf)
+	}
+	if start >= end {
+		return nil
+	}
+
+	deleted := append([]rune(nil), l.buf[start:end]...)
+	l.buf = append(l.buf[:start], l.buf[end:]...)
+	if l.pos > end {
+		l.pos -= end - start
+	} else if l.pos > start {
+		l.pos = start
+	}
+	return deleted
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordStart is where the word before the cursor starts
+func (l *line) wordStart() int {
+	i := l.pos
+	for i > 0 && !isWordRune(l.buf[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(l.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd is where the word after the cursor ends
+func (l *line) wordEnd() int {
+	i := l.pos
+	for i < len(l.buf) && !isWordRune(l.buf[i]) {
+		i++
+	}
+	for i < len(l.buf) && isWordRune(l.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// kill deletes buf[start:end] keeping it to be yanked
+func (e *Editor) kill(l *line, start, end int) {
+	if killed := l.delete(start, end); len(killed) > 0 {
+		e.yanked = killed
+	}
+}
+
+// recall shows the history entry at index, the line being typed is kept
+// to come back to it past the newest entry
+func (e *Editor) recall(l *line, index int) {
+	if index < 0 || index > e.History.Len() {
+		return
+	}
+
+	if l.index == e.History.Len() {
+		l.editing = append([]rune(nil), l.buf...)
+	}
+
+	l.index = index
+	if index == e.History.Len() {
+		l.buf = append([]rune(nil), l.editing...)
+	} else {
+		l.buf = []rune(e.History.Entry(index))
+	}
+	l.pos = len(l.buf)
+}
+
+// search is the incremental history search started with Ctrl-R. Typing
+// narrows it, Ctrl-R again finds an older match and Ctrl-G cancels it.
+// Any other key takes the match into the line and is returned to be
+// handled as usual.
+func (e *Editor) search(l *line) (rune, error) {
+	var query []rune
+	match := -1
+	failing := false
+
+	for {
+		status := "reverse-i-search"
+		if failing {
+			status = "failing " + status
+		}
+
+		found, pos := []rune(nil), 0
+		if match >= 0 {
+			found = []rune(e.History.Entry(match))
+			if i := strings.Index(string(found), string(query)); i > 0 {
+				pos = len([]rune(string(found)[:i]))
+			}
+		}
+		e.render(fmt.Sprintf("(%s)`%s': ", status, string(query)), found, pos)
+
+		k, err := e.readKey()
+		if err != nil {
+			return 0, err
+		}
+
+		from := e.History.Len() - 1
+		switch {
+		case k == ctrl('r'):
+			if match < 0 {
+				continue
+			}
+			from = match - 1
+		case k == keyBackspace || k == ctrl('h'):
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+		case k == ctrl('g'):
+			return 0, nil
+		case k >= ' ' && unicode.IsPrint(k):
+			query = append(query, k)
+			if match >= 0 {
+				from = match
+			}
+		default:
+			if match >= 0 {
+				l.buf = found
+				l.pos = pos
+				l.index = e.History.Len()
+			}
+			return k, nil
+		}
+
+		if len(query) == 0 {
+			match, failing = -1, false
+		} else if i := e.History.search(string(query), from); i >= 0 {
+			match, failing = i, false
+		} else {
+			failing = true
+		}
+	}
+}
+
+// readKey reads a rune or decodes an escape sequence
+func (e *Editor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+
+	switch r {
+	case 'b':
+		return keyWordLeft, nil
+	case 'f':
+		return keyWordRight, nil
+	case 'd':
+		return keyKillWord, nil
+	case keyBackspace:
+		return keyKillPrevWord, nil
+	case '[', 'O':
+		return e.readSequence()
+	}
+	return keyUnknown, nil
+}
+
+// readSequence decodes what follows ESC [ or ESC O, parameters up to a
+// final byte
+func (e *Editor) readSequence() (rune, error) {
+	var params []byte
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b < 0x40 || b > 0x7e {
+			params = append(params, b)
+			continue
+		}
+
+		// a modifier, like Ctrl in 1;5C, turns arrows into word moves
+		modified := strings.Contains(string(params), ";")
+
+		switch b {
+		case 'A':
+			return keyUp, nil
+		case 'B':
+			return keyDown, nil
+		case 'C':
+			if modified {
+				return keyWordRight, nil
+			}
+			return keyRight, nil
+		case 'D':
+			if modified {
+				return keyWordLeft, nil
+			}
+			return keyLeft, nil
+		case 'H':
+			return keyHome, nil
+		case 'F':
+			return keyEnd, nil
+		case '~':
+			switch string(params) {
+			case "1", "7":
+				return keyHome, nil
+			case "4", "8":
+				return keyEnd, nil
+			case "3":
+				return keyDelete, nil
+			}
+		}
+		return keyUnknown, nil
+	}
+}
+
+func (e *Editor) write(s string) {
+	io.WriteString(e.out, s)
+}
+
+func (e *Editor) refresh(l *line) {
+	e.render(l.prompt, l.buf, l.pos)
+}
+
+// render redraws the line, it scrolls sideways when it does not fit in the
+// terminal so the cursor is always visible
+func (e *Editor) render(prompt string, buf []rune, pos int) {
+	cols := defaultWidth
+	if e.fd >= 0 {
+		if w := width(e.fd); w > 0 {
+			cols = w
+		}
+	}
+
+	promptWidth := len([]rune(prompt))
+	room := cols - promptWidth - 1
+	if room < 1 {
+		room = 1
+	}
+
+	start := 0
+	if pos > room {
+		start = pos - room
+	}
+	end := len(buf)
+	if end-start > room {
+		end = start + room
+	}
+
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	b.WriteString(string(buf[start:end]))
+	b.WriteString("\x1b[K\r")
+	if column := promptWidth + pos - start; column > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", column)
+	}
+	e.write(b.String())
+}
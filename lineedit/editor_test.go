@@ -0,0 +1,129 @@
+package lineedit
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func newTestEditor(keys string, history ...string) *Editor {
+	e := New(strings.NewReader(keys), &bytes.Buffer{})
+	for _, entry := range history {
+		e.History.Add(entry)
+	}
+	return e
+}
+
+func TestReadLine(t *testing.T) {
+	history := []string{"let a = 1;", "let b = 2;", "a + b"}
+
+	tests := []struct {
+		name     string
+		keys     string
+		expected string
+	}{
+		{"typing", "let x = 5;\r", "let x = 5;"},
+		{"newline", "1 + 2\n", "1 + 2"},
+		{"unicode", "\"héllo\"\r", "\"héllo\""},
+		{"backspace", "abcd\x7f\x7fX\r", "abX"},
+		{"arrows", "abc\x1b[D\x1b[DX\x1b[CY\r", "aXbYc"},
+		{"ctrl moves", "abc\x02\x02X\x06Y\r", "aXbYc"},
+		{"home and end", "bc\x1b[Ha\x1b[Fd\r", "abcd"},
+		{"home and end sequences", "bc\x1b[1~a\x1b[4~d\x1bOHz\r", "zabcd"},
+		{"ctrl-a and ctrl-e", "bc\x01a\x05d\r", "abcd"},
+		{"delete", "abc\x01\x1b[3~\r", "bc"},
+		{"ctrl-d deletes", "abc\x01\x04\r", "bc"},
+		{"word moves", "foo bar baz\x1bb\x1bbX\x1bfY\r", "foo XbarY baz"},
+		{"ctrl arrows", "foo bar\x1b[1;5DX\r", "foo Xbar"},
+		{"kill to end and yank", "abcdef\x02\x02\x02\x0b\x01\x19\r", "defabc"},
+		{"kill to start", "abcdef\x02\x02\x15X\r", "Xef"},
+		{"kill previous word", "let x = foo(bar\x17baz\x17\x17y\r", "let x y"},
+		{"alt kills", "foo.bar baz\x01\x1bdX\x05\x1b\x7f\x19\x19\r", "X.bar bazbaz"},
+		{"tab", "\tx\r", "    x"},
+		{"unknown keys", "a\x1b[Zb\x1bxc\r", "abc"},
+		{"previous", "\x1b[A\r", "a + b"},
+		{"previous twice", "\x1b[A\x10\r", "let b = 2;"},
+		{"past the oldest", "\x1b[A\x1b[A\x1b[A\x1b[A\x1b[A\r", "let a = 1;"},
+		{"back to the new line", "typed\x1b[A\x1b[A\x1b[B\x0e\r", "typed"},
+		{"edit recalled", "\x10\x7f+ 1\r", "a + + 1"},
+		{"search", "\x12let\r", "let b = 2;"},
+		{"search older", "\x12let\x12\r", "let a = 1;"},
+		{"search narrows", "\x12b\x12;\r", "let b = 2;"},
+		{"search backspace", "\x12let b\x7f\x7f\x7f\x7f\x7fa\r", "a + b"},
+		{"search failing keeps match", "\x12a + b!\r", "a + b"},
+		{"search accepts on key", "\x12let a\x05 + 1\r", "let a = 1; + 1"},
+		{"search cancel", "typed\x12let\x07!\r", "typed!"},
+		{"search without match", "typed\x12zzz\x05!\r", "typed!"},
+		{"end of input", "no newline", "no newline"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys, history...)
+
+		line, err := e.ReadLine(">> ")
+		if err != nil {
+			t.Errorf("%s: expected no error but got %v", tt.name, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%s: expected line %q but got %q", tt.name, tt.expected, line)
+		}
+	}
+}
+
+func TestReadLineEnds(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected error
+	}{
+		{"", io.EOF},
+		{"\x04", io.EOF},
+		{"abc\x03", ErrInterrupted},
+		{"\x12abc\x03", ErrInterrupted},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys, "abc")
+
+		line, err := e.ReadLine(">> ")
+		if err != tt.expected {
+			t.Errorf("%q: expected error %v but got %v", tt.keys, tt.expected, err)
+		}
+		if line != "" {
+			t.Errorf("%q: expected no line but got %q", tt.keys, line)
+		}
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	e := newTestEditor("first\rsecond\r\x10\x10\r")
+
+	for _, expected := range []string{"first", "second", "first"} {
+		line, err := e.ReadLine(">> ")
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		if line != expected {
+			t.Errorf("Expected line %q but got %q", expected, line)
+		}
+		e.History.Add(line)
+	}
+
+	if _, err := e.ReadLine(">> "); err != io.EOF {
+		t.Errorf("Expected io.EOF but got %v", err)
+	}
+}
+
+func TestRenderScrolls(t *testing.T) {
+	var out bytes.Buffer
+	e := New(strings.NewReader(""), &out)
+
+	buf := []rune(strings.Repeat("a", 100) + "bcd")
+	e.render(">> ", buf, len(buf))
+
+	expected := "\r>> " + strings.Repeat("a", 73) + "bcd\x1b[K\r\x1b[79C"
+	if out.String() != expected {
+		t.Errorf("Expected\n%q\nbut got\n%q", expected, out.String())
+	}
+}
@@ -0,0 +1,116 @@
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultHistorySize is how many lines a new History keeps
+const DefaultHistorySize = 1000
+
+// History is the list of lines entered, oldest first
+type History struct {
+	entries []string
+	max     int
+}
+
+// NewHistory makes a history keeping up to max lines, the oldest are
+// dropped first
+func NewHistory(max int) *History {
+	return &History{max: max}
+}
+
+// Add appends line, empty lines and repetitions of the last one are not kept
+func (h *History) Add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return
+	}
+
+	h.entries = append(h.entries, line)
+	if h.max > 0 && len(h.entries) > h.max {
+		h.entries = h.entries[len(h.entries)-h.max:]
+	}
+}
+
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// Entry returns the i-th line, 0 being the oldest
+func (h *History) Entry(i int) string {
+	return h.entries[i]
+}
+
+// search looks for query backwards starting at from, it returns the index
+// of the first entry containing it or -1
+func (h *History) search(query string, from int) int {
+	if from >= len(h.entries) {
+		from = len(h.entries) - 1
+	}
+	for i := from; i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Load adds the lines read from r
+func (h *History) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		h.Add(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// Save writes a line per entry to w
+func (h *History) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, entry := range h.entries {
+		bw.WriteString(entry)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// LoadFile adds the lines of the file at path, a missing file is not an
+// error, there is no history yet
+func (h *History) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return h.Load(f)
+}
+
+// SaveFile writes the history to the file at path, creating its directory
+func (h *History) SaveFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
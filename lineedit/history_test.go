@@ -0,0 +1,84 @@
+package lineedit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func entries(h *History) []string {
+	list := []string{}
+	for i := 0; i < h.Len(); i++ {
+		list = append(list, h.Entry(i))
+	}
+	return list
+}
+
+func TestHistoryAdd(t *testing.T) {
+	h := NewHistory(3)
+	for _, line := range []string{"a", "", "  ", "b", "b", "c", "b", "d"} {
+		h.Add(line)
+	}
+
+	expected := []string{"c", "b", "d"}
+	if got := entries(h); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected entries %q but got %q", expected, got)
+	}
+}
+
+func TestHistorySearch(t *testing.T) {
+	h := NewHistory(DefaultHistorySize)
+	h.Load(strings.NewReader("let a = 1;\nlet b = 2;\na + b\n"))
+
+	tests := []struct {
+		query    string
+		from     int
+		expected int
+	}{
+		{"let", 2, 1},
+		{"let", 10, 1},
+		{"let", 0, 0},
+		{"a", 2, 2},
+		{"a", 1, 0},
+		{"c", 2, -1},
+	}
+
+	for _, tt := range tests {
+		if got := h.search(tt.query, tt.from); got != tt.expected {
+			t.Errorf("search(%q, %d): expected %d but got %d", tt.query, tt.from, tt.expected, got)
+		}
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monkey", "history")
+
+	h := NewHistory(DefaultHistorySize)
+	if err := h.LoadFile(path); err != nil {
+		t.Fatalf("Expected a missing file to be no error but got %v", err)
+	}
+
+	h.Add("let a = 1;")
+	h.Add("a * 2")
+	if err := h.SaveFile(path); err != nil {
+		t.Fatalf("Expected no error saving but got %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "let a = 1;\na * 2\n" {
+		t.Errorf("Wrong file content %q", content)
+	}
+
+	loaded := NewHistory(DefaultHistorySize)
+	if err := loaded.LoadFile(path); err != nil {
+		t.Fatalf("Expected no error loading but got %v", err)
+	}
+	if !reflect.DeepEqual(entries(loaded), entries(h)) {
+		t.Errorf("Expected entries %q but got %q", entries(h), entries(loaded))
+	}
+}
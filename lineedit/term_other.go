@@ -0,0 +1,25 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package lineedit
+
+import "errors"
+
+type termState struct{}
+
+// IsTerminal tells if fd is a terminal, raw mode is not supported on this
+// platform so it never is
+func IsTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (*termState, error) {
+	return nil, errors.New("lineedit: raw mode not supported")
+}
+
+func restore(fd int, state *termState) error {
+	return nil
+}
+
+func width(fd int) int {
+	return 0
+}
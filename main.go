@@ -1,20 +1,105 @@
 package main
 
 import (
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/compiler"
+	"cprieto.com/monkey/evaluator"
+	"cprieto.com/monkey/lexer"
+	"cprieto.com/monkey/object"
+	"cprieto.com/monkey/parser"
 	"cprieto.com/monkey/repl"
+	"cprieto.com/monkey/vm"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:]))
+	}
+
+	engine := flag.String("engine", "eval", "engine running the program or the REPL input, `eval` or vm")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: monkey [-engine=eval|vm] [file]\n       monkey fmt [-d] [-w] [path ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *engine != "eval" && *engine != "vm" {
+		fmt.Fprintf(os.Stderr, "unknown engine %q, expected eval or vm\n", *engine)
+		os.Exit(2)
+	}
+
+	if flag.NArg() > 0 {
+		if err := runFile(*engine, flag.Arg(0)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	u, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 
 	fmt.Printf("Hello %s! This is the Monkey Programminig Language!\n", u.Username)
-	fmt.Printf("Feel free to type in commands\n")
+	fmt.Printf("Feel free to type in commands, :help lists the REPL ones\n")
+
+	repl.Start(os.Stdin, os.Stdout, repl.Engine(*engine))
+}
+
+// runFile runs a script with the given engine and prints its result
+func runFile(engine, filename string) error {
+	input, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	p := parser.New(lexer.NewFile(filename, string(input)))
+	program := p.ParseProgram()
+	if err := p.Errors().Err(); err != nil {
+		return err
+	}
+
+	var result object.Object
+	if engine == "vm" {
+		result, err = runVM(program)
+	} else {
+		result, err = runEval(program)
+	}
+	if err != nil {
+		return err
+	}
+
+	if result != nil {
+		fmt.Println(result.Inspect())
+	}
+
+	return nil
+}
+
+func runEval(program *ast.Program) (object.Object, error) {
+	result := evaluator.Eval(program, object.NewEnvironment())
+	if err, ok := result.(*object.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+	return result, nil
+}
+
+func runVM(program *ast.Program) (object.Object, error) {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
 
-	repl.Start(os.Stdin, os.Stdout)
+	return machine.LastPoppedStackElem(), nil
 }
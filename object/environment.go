@@ -0,0 +1,34 @@
+package object
+
+// Environment binds names to values, lookups fall back to the outer scope
+// when the name is not bound locally
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+// NewEnclosedEnvironment creates a scope nested inside outer, as used when
+// calling a function
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set always binds in the current scope, shadowing any outer binding
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
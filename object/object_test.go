@@ -0,0 +1,47 @@
+package object
+
+import "testing"
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestStringHashKeyKeepsText(t *testing.T) {
+	// the key holds the text itself, not a digest two strings could share
+	keys := make(map[HashKey]string)
+	for _, value := range []string{"", "a", "b", "ab", "ba", "a\x00", "\x00a"} {
+		key := (&String{Value: value}).HashKey()
+		if other, ok := keys[key]; ok {
+			t.Errorf("strings %q and %q have the same hash key", other, value)
+		}
+		keys[key] = value
+
+		if key.Text != value {
+			t.Errorf("Expected the hash key of %q to keep its text but got %q", value, key.Text)
+		}
+	}
+}
+
+func TestHashKeyTypes(t *testing.T) {
+	one := &Integer{Value: 1}
+	yes := &Boolean{Value: true}
+
+	if one.HashKey() == yes.HashKey() {
+		t.Errorf("integer 1 and true have the same hash key")
+	}
+}
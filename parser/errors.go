@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"cprieto.com/monkey/token"
+	"fmt"
+	"sort"
+)
+
+type ErrorKind int
+
+const (
+	// UnexpectedToken is reported when the parser expected a given token and
+	// found a different one
+	UnexpectedToken ErrorKind = iota
+	// NoPrefixParse is reported when a token cannot start an expression
+	NoPrefixParse
+	// IllegalToken is reported for tokens the lexer could not make sense of
+	IllegalToken
+	// InvalidLiteral is reported when a literal cannot be converted to a value
+	InvalidLiteral
+	// IntegerOverflow is reported for integer literals that do not fit in int64
+	IntegerOverflow
+	// FloatOverflow is reported for float literals beyond the float64 range
+	FloatOverflow
+)
+
+var errorKindNames = map[ErrorKind]string{
+	UnexpectedToken: "unexpected token",
+	NoPrefixParse:   "no prefix parse function",
+	IllegalToken:    "illegal token",
+	InvalidLiteral:  "invalid literal",
+	IntegerOverflow: "integer overflow",
+	FloatOverflow:   "float overflow",
+}
+
+func (k ErrorKind) String() string {
+	if name, ok := errorKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+// ParseError is a single problem found while parsing. Expected is only set
+// for UnexpectedToken errors, Found is the offending token.
+type ParseError struct {
+	Pos      token.Position
+	Kind     ErrorKind
+	Expected token.TokenType
+	Found    token.Token
+	Msg      string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ErrorList is a list of parse errors, it implements sort.Interface ordering
+// errors by file and position
+type ErrorList []*ParseError
+
+func (l ErrorList) Len() int {
+	return len(l)
+}
+
+func (l ErrorList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (l ErrorList) Less(i, j int) bool {
+	a, b := l[i].Pos, l[j].Pos
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Offset != b.Offset {
+		return a.Offset < b.Offset
+	}
+	return l[i].Msg < l[j].Msg
+}
+
+// Sort sorts the list in place
+func (l ErrorList) Sort() {
+	sort.Sort(l)
+}
+
+// Dedup returns a sorted copy of the list without repeated errors, two
+// errors are the same when they share position, kind and message
+func (l ErrorList) Dedup() ErrorList {
+	sorted := make(ErrorList, len(l))
+	copy(sorted, l)
+	sorted.Sort()
+
+	result := ErrorList{}
+	for i, e := range sorted {
+		if i > 0 {
+			last := result[len(result)-1]
+			if last.Pos == e.Pos && last.Kind == e.Kind && last.Msg == e.Msg {
+				continue
+			}
+		}
+		result = append(result, e)
+	}
+
+	return result
+}
+
+// Filter returns the errors for which keep returns true
+func (l ErrorList) Filter(keep func(*ParseError) bool) ErrorList {
+	result := ErrorList{}
+	for _, e := range l {
+		if keep(e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Error describes the first error and how many more there are
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns nil for an empty list so it can be returned as an error
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
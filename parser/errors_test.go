@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"cprieto.com/monkey/lexer"
+	"cprieto.com/monkey/token"
+	"testing"
+)
+
+func TestParseErrorFields(t *testing.T) {
+	l := lexer.NewFile("main.mk", "let x 5;")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("Expected a parsing error but got nothing")
+	}
+
+	err := p.Errors()[0]
+	if err.Kind != UnexpectedToken {
+		t.Errorf("Expected kind `%s` but got `%s`", UnexpectedToken, err.Kind)
+	}
+
+	if err.Expected != token.ASSIGN {
+		t.Errorf("Expected token `%s` but got `%s`", token.ASSIGN, err.Expected)
+	}
+
+	if err.Found.Type != token.INT || err.Found.Literal != "5" {
+		t.Errorf("Expected found token INT `5` but got %s `%s`", err.Found.Type, err.Found.Literal)
+	}
+
+	if err.Pos.String() != "main.mk:1:7" {
+		t.Errorf("Expected position `main.mk:1:7` but got `%s`", err.Pos)
+	}
+}
+
+func TestParseErrorKinds(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  ErrorKind
+	}{
+		{"let = 5;", UnexpectedToken},
+		{"*5", NoPrefixParse},
+		{`"unterminated`, IllegalToken},
+		{"99999999999999999999", IntegerOverflow},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("Expected a parsing error for `%s` but got nothing", tt.input)
+		}
+
+		if p.Errors()[0].Kind != tt.kind {
+			t.Errorf("Expected kind `%s` for `%s` but got `%s`", tt.kind, tt.input, p.Errors()[0].Kind)
+		}
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	at := func(offset int, msg string) *ParseError {
+		return &ParseError{Pos: token.Position{Offset: offset, Line: 1, Column: offset + 1}, Msg: msg}
+	}
+
+	list := ErrorList{at(5, "b"), at(1, "a"), at(5, "b"), at(3, "c")}
+
+	deduped := list.Dedup()
+	if len(deduped) != 3 {
+		t.Fatalf("Expected 3 errors but got %d", len(deduped))
+	}
+
+	for i, msg := range []string{"a", "c", "b"} {
+		if deduped[i].Msg != msg {
+			t.Errorf("Expected error %d to be `%s` but got `%s`", i, msg, deduped[i].Msg)
+		}
+	}
+
+	if len(list) != 4 || list[0].Msg != "b" {
+		t.Errorf("Dedup should not modify the original list")
+	}
+
+	filtered := list.Filter(func(e *ParseError) bool { return e.Msg == "b" })
+	if len(filtered) != 2 {
+		t.Errorf("Expected 2 filtered errors but got %d", len(filtered))
+	}
+
+	if list.Error() != "1:6: b (and 3 more errors)" {
+		t.Errorf("Unexpected error message `%s`", list.Error())
+	}
+
+	if (ErrorList{}).Err() != nil {
+		t.Errorf("Expected a nil error for an empty list")
+	}
+
+	if list.Err() == nil {
+		t.Errorf("Expected an error for a non empty list")
+	}
+}
+
+func TestLexerErrorsReportedOnce(t *testing.T) {
+	l := lexer.New("let x = \xff;\nlet @ = 1;\nlet y = 2;")
+	p := New(l)
+	program := p.ParseProgram()
+
+	expected := []string{
+		"1:9: invalid UTF-8 encoding",
+		"2:5: unexpected character '@'",
+	}
+
+	if len(p.Errors()) != len(expected) {
+		t.Fatalf("Expected %d errors but got %d: %v", len(expected), len(p.Errors()), p.Errors())
+	}
+
+	for i, msg := range expected {
+		if p.Errors()[i].Error() != msg {
+			t.Errorf("Expected error `%s` but got `%s`", msg, p.Errors()[i])
+		}
+		if p.Errors()[i].Kind != IllegalToken {
+			t.Errorf("Expected kind `%s` but got `%s`", IllegalToken, p.Errors()[i].Kind)
+		}
+	}
+
+	if program.Statements[2].String() != "let y = 2;" {
+		t.Errorf("Expected the last statement to parse but got `%s`", program.Statements[2])
+	}
+}
@@ -4,21 +4,52 @@ import (
 	"cprieto.com/monkey/ast"
 	"cprieto.com/monkey/lexer"
 	"cprieto.com/monkey/token"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	LOGICAL_OR
+	LOGICAL_AND
 	EQUALS
 	LESSGREATER
 	SUM
 	PRODUCT
 	PREFIX
 	CALL
+	INDEX
 )
 
+// bitwise operators share levels with arithmetic as they do in Go: `| ^`
+// with the sums and `& << >>` with the products
+var precedences = map[token.TokenType]int{
+	token.OR:        LOGICAL_OR,
+	token.AND:       LOGICAL_AND,
+	token.EQ:        EQUALS,
+	token.NE:        EQUALS,
+	token.LT:        LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.LE:        LESSGREATER,
+	token.GE:        LESSGREATER,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.PIPE:      SUM,
+	token.CARET:     SUM,
+	token.SLASH:     PRODUCT,
+	token.ASTERISK:  PRODUCT,
+	token.PERCENT:   PRODUCT,
+	token.AMPERSAND: PRODUCT,
+	token.SHL:       PRODUCT,
+	token.SHR:       PRODUCT,
+	token.LPAREN:    CALL,
+	token.LBRACKET:  INDEX,
+}
+
 type (
 	prefixParseFn func() ast.Expression
 	infixParseFn  func(ast.Expression) ast.Expression
@@ -28,42 +59,102 @@ type Parser struct {
 	lxr     *lexer.Lexer
 	current token.Token
 	peek    token.Token
-	errors  []string
+	errors  ErrorList
+
+	lexErrors int // lexer errors already copied into errors
+
+	depth       int            // braces opened and not closed before current
+	previousEnd token.Position // of the token before current
 
 	prefixFn map[token.TokenType]prefixParseFn
 	infixFn  map[token.TokenType]infixParseFn
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{lxr: l, errors: []string{}}
+	p := &Parser{lxr: l, errors: ErrorList{}}
 	p.prefixFn = make(map[token.TokenType]prefixParseFn)
+	p.infixFn = make(map[token.TokenType]infixParseFn)
 	p.nextToken()
 	p.nextToken()
 
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TRUE, p.parseBoolean)
+	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+
+	p.registerInfix(token.PLUS, p.parseInfixExpression)
+	p.registerInfix(token.MINUS, p.parseInfixExpression)
+	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.EQ, p.parseInfixExpression)
+	p.registerInfix(token.NE, p.parseInfixExpression)
+	p.registerInfix(token.LT, p.parseInfixExpression)
+	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LE, p.parseInfixExpression)
+	p.registerInfix(token.GE, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
 	return p
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() ErrorList {
 	return p.errors
 }
 
 func (p *Parser) nextToken() {
+	switch p.current.Type {
+	case token.LBRACE:
+		p.depth++
+	case token.RBRACE:
+		p.depth--
+	}
+	p.previousEnd = p.current.End
+
 	p.current = p.peek
 	p.peek = p.lxr.NextToken()
+
+	// the lexer explains every ILLEGAL token it returns, report that
+	// explanation once instead of a generic error wherever the token shows up
+	for _, err := range p.lxr.Errors()[p.lexErrors:] {
+		p.errors = append(p.errors, &ParseError{
+			Pos:   err.Pos,
+			Kind:  IllegalToken,
+			Found: p.peek,
+			Msg:   err.Msg,
+		})
+	}
+	p.lexErrors = len(p.lxr.Errors())
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 	for p.current.Type != token.EOF {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+		stmt, resume := p.parseStatementOrBad()
+		program.Statements = append(program.Statements, stmt)
+
+		// a `}` left by recovery closes nothing at the top level
+		if resume && !p.currentTokenIs(token.RBRACE) {
+			continue
 		}
 		p.nextToken()
 	}
@@ -71,15 +162,80 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// parseStatementOrBad parses a statement, when it fails the parser is
+// synchronised to the next statement boundary and an ast.BadStatement
+// spanning the skipped tokens takes the statement's place. resume reports
+// that recovery stopped on the first token after the statement rather than
+// on its last one.
+func (p *Parser) parseStatementOrBad() (stmt ast.Statement, resume bool) {
+	start, depth := p.current, p.depth
+
+	if stmt := p.parseStatement(); stmt != nil {
+		return stmt, false
+	}
+
+	end, resume := p.synchronize(start, depth)
+	return &ast.BadStatement{Token: start, End: end}, resume
+}
+
+// synchronize skips tokens after a failed statement so parsing can resume
+// at the next one. Braces are counted from the start of the statement, so
+// only a `}` closing a block opened before it counts as a boundary. Outside
+// the braces the statement opened, it stops on a `;` ending the statement,
+// or on or before a `let`, `return` or `}` that follows it. It returns the
+// end of the skipped tokens and whether it stopped on the token after them.
+func (p *Parser) synchronize(start token.Token, base int) (token.Position, bool) {
+	for !p.currentTokenIs(token.EOF) {
+		depth := p.depth - base
+
+		if depth == 0 && p.current != start &&
+			(p.currentTokenIs(token.LET) || p.currentTokenIs(token.RETURN) || p.currentTokenIs(token.RBRACE)) {
+			return p.previousEnd, true
+		}
+
+		switch p.current.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			// a statement can only start with a `}` at the top level
+			if depth == 0 {
+				return p.current.End, false
+			}
+			depth--
+		case token.SEMICOLON:
+			if depth == 0 {
+				return p.current.End, false
+			}
+		}
+
+		if depth == 0 && (p.peekTokenIs(token.LET) || p.peekTokenIs(token.RETURN) || p.peekTokenIs(token.RBRACE)) {
+			return p.current.End, false
+		}
+
+		p.nextToken()
+	}
+
+	return p.current.End, false
+}
+
 func (p *Parser) parseStatement() ast.Statement {
+	// the parse functions return typed pointers, a nil one must not leak
+	// into the Statement interface as a non-nil value
 	switch p.current.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
 	default:
-		return p.parseExpressionStatement()
+		if stmt := p.parseExpressionStatement(); stmt != nil {
+			return stmt
+		}
 	}
+	return nil
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
@@ -93,8 +249,17 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		return nil
 	}
 
-	// TODO: Not doing expressions (yet)
-	for !p.currentTokenIs(token.SEMICOLON) {
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fn.Name = stmt.Name.Value
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -118,17 +283,53 @@ func (p *Parser) currentTokenIs(t token.TokenType) bool {
 	return p.current.Type == t
 }
 
+// Precedence is the binding power of an infix operator, LOWEST for anything
+// that is not one
+func Precedence(operator string) int {
+	if prec, ok := precedences[token.TokenType(operator)]; ok {
+		return prec
+	}
+	return LOWEST
+}
+
+func (p *Parser) peekPrecedence() int {
+	if prec, ok := precedences[p.peek.Type]; ok {
+		return prec
+	}
+	return LOWEST
+}
+
+func (p *Parser) currentPrecedence() int {
+	if prec, ok := precedences[p.current.Type]; ok {
+		return prec
+	}
+	return LOWEST
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token `%s` but got `%s`", t, p.peek.Type)
-	p.errors = append(p.errors, msg)
+	if p.peekTokenIs(token.ILLEGAL) {
+		return // already reported by the lexer
+	}
+
+	p.errors = append(p.errors, &ParseError{
+		Pos:      p.peek.Start,
+		Kind:     UnexpectedToken,
+		Expected: t,
+		Found:    p.peek,
+		Msg:      fmt.Sprintf("expected next token `%s` but got `%s`", t, p.peek.Type),
+	})
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.current}
 	p.nextToken()
 
-	// TODO: We don't support expressions
-	for !p.currentTokenIs(token.SEMICOLON) {
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
@@ -146,6 +347,9 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.current}
 
 	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -154,13 +358,30 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
-func (p *Parser) parseExpression(lowest int) ast.Expression {
+func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixFn[p.current.Type]
 	if prefix == nil {
 		p.noPrefixParseError(p.current.Type)
 		return nil
 	}
 	leftExpr := prefix()
+	if leftExpr == nil {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+		infix := p.infixFn[p.peek.Type]
+		if infix == nil {
+			return leftExpr
+		}
+
+		p.nextToken()
+		leftExpr = infix(leftExpr)
+		if leftExpr == nil {
+			return nil
+		}
+	}
+
 	return leftExpr
 }
 
@@ -171,10 +392,23 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	literal := &ast.IntegerLiteral{Token: p.current}
-	value, err := strconv.ParseInt(p.current.Literal, 0, 64)
+	value, err := parseInteger(p.current.Literal, false)
+	if errors.Is(err, strconv.ErrRange) {
+		p.errors = append(p.errors, &ParseError{
+			Pos:   p.current.Start,
+			Kind:  IntegerOverflow,
+			Found: p.current,
+			Msg:   fmt.Sprintf("integer literal %s overflows int64", p.current.Literal),
+		})
+		return nil
+	}
 	if err != nil {
-		msg := fmt.Sprintf("Couldn't parse %q as integer", p.current.Literal)
-		p.errors = append(p.errors, msg)
+		p.errors = append(p.errors, &ParseError{
+			Pos:   p.current.Start,
+			Kind:  InvalidLiteral,
+			Found: p.current,
+			Msg:   fmt.Sprintf("Couldn't parse %q as integer", p.current.Literal),
+		})
 		return nil
 	}
 
@@ -182,16 +416,393 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return literal
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	literal := &ast.FloatLiteral{Token: p.current}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(p.current.Literal, "_", ""), 64)
+	if errors.Is(err, strconv.ErrRange) && math.IsInf(value, 0) {
+		p.errors = append(p.errors, &ParseError{
+			Pos:   p.current.Start,
+			Kind:  FloatOverflow,
+			Found: p.current,
+			Msg:   fmt.Sprintf("float literal %s overflows float64", p.current.Literal),
+		})
+		return nil
+	}
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		p.errors = append(p.errors, &ParseError{
+			Pos:   p.current.Start,
+			Kind:  InvalidLiteral,
+			Found: p.current,
+			Msg:   fmt.Sprintf("Couldn't parse %q as float", p.current.Literal),
+		})
+		return nil
+	}
+
+	// literals too small to represent round to zero
+	literal.Value = value
+	return literal
+}
+
+// parseInteger converts an INT literal, negated when negative, prefixed
+// literals use their base while anything else is decimal, even with leading
+// zeros
+func parseInteger(literal string, negative bool) (int64, error) {
+	sign := ""
+	if negative {
+		sign = "-"
+	}
+
+	if len(literal) > 1 && literal[0] == '0' && strings.ContainsAny(literal[1:2], "xXbBoO") {
+		return strconv.ParseInt(sign+literal, 0, 64)
+	}
+	return strconv.ParseInt(sign+strings.ReplaceAll(literal, "_", ""), 10, 64)
+}
+
+// parseNegativeLiteral folds a minus into the integer literal after it when
+// the literal only fits in an int64 negated, like -9223372036854775808
+func (p *Parser) parseNegativeLiteral() ast.Expression {
+	if !p.currentTokenIs(token.MINUS) || !p.peekTokenIs(token.INT) {
+		return nil
+	}
+	if _, err := parseInteger(p.peek.Literal, false); !errors.Is(err, strconv.ErrRange) {
+		return nil
+	}
+	value, err := parseInteger(p.peek.Literal, true)
+	if err != nil {
+		return nil
+	}
+
+	minus := p.current
+	p.nextToken()
+	return &ast.IntegerLiteral{
+		Token: token.Token{
+			Type:    token.INT,
+			Literal: "-" + p.current.Literal,
+			Start:   minus.Start,
+			End:     p.current.End,
+		},
+		Value: value,
+	}
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.current, Value: p.current.Literal}
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: p.current, Value: p.currentTokenIs(token.TRUE)}
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.current}
+
+	elements, ok := p.parseExpressionList(token.RBRACKET)
+	if !ok {
+		return nil
+	}
+	array.Elements = elements
+
+	return array
+}
+
+// parseHashLiteral handles `{` in expression position. Blocks also start with
+// `{` but they are only parsed where the grammar demands one (if/else and
+// function bodies), so anywhere else the brace opens a hash.
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.current}
+	hash.Pairs = []ast.HashPair{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if key == nil {
+			return nil
+		}
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return hash
+}
+
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+	if exp == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return exp
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	if literal := p.parseNegativeLiteral(); literal != nil {
+		return literal
+	}
+
 	exp := &ast.PrefixExpression{Token: p.current, Operator: p.current.Literal}
 
 	p.nextToken()
 	exp.Right = p.parseExpression(PREFIX)
+	if exp.Right == nil {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseIfExpression() ast.Expression {
+	exp := &ast.IfExpression{Token: p.current}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Condition = p.parseExpression(LOWEST)
+	if exp.Condition == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	// `then` is optional: `if (x) then { ... }` and `if (x) { ... }` are the same
+	if p.peekTokenIs(token.THEN) {
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Consequence = p.parseBlockStatement()
+	if exp.Consequence == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.ELSE) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		exp.Alternative = p.parseBlockStatement()
+		if exp.Alternative == nil {
+			return nil
+		}
+	}
 
 	return exp
 }
 
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.current}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+
+	for !p.currentTokenIs(token.RBRACE) {
+		if p.currentTokenIs(token.EOF) {
+			p.unexpectedTokenError(token.RBRACE, p.current)
+			return nil
+		}
+
+		stmt, resume := p.parseStatementOrBad()
+		block.Statements = append(block.Statements, stmt)
+
+		// recovery may stop right on the closing brace or the next statement
+		if resume {
+			continue
+		}
+		p.nextToken()
+	}
+
+	return block
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	fn := &ast.FunctionLiteral{Token: p.current}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	params, ok := p.parseFunctionParameters()
+	if !ok {
+		return nil
+	}
+	fn.Parameters = params
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	fn.Body = p.parseBlockStatement()
+	if fn.Body == nil {
+		return nil
+	}
+
+	return fn
+}
+
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, bool) {
+	identifiers := []*ast.Identifier{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return identifiers, true
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil, false
+	}
+	identifiers = append(identifiers, &ast.Identifier{Token: p.current, Value: p.current.Literal})
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil, false
+		}
+		identifiers = append(identifiers, &ast.Identifier{Token: p.current, Value: p.current.Literal})
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, false
+	}
+
+	return identifiers, true
+}
+
+func (p *Parser) unexpectedTokenError(t token.TokenType, got token.Token) {
+	if got.Type == token.ILLEGAL {
+		return // already reported by the lexer
+	}
+
+	p.errors = append(p.errors, &ParseError{
+		Pos:      got.Start,
+		Kind:     UnexpectedToken,
+		Expected: t,
+		Found:    got,
+		Msg:      fmt.Sprintf("expected token `%s` but got `%s`", t, got.Type),
+	})
+}
+
 func (p *Parser) noPrefixParseError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s", t)
-	p.errors = append(p.errors, msg)
+	if t == token.ILLEGAL {
+		return // already reported by the lexer
+	}
+
+	p.errors = append(p.errors, &ParseError{
+		Pos:   p.current.Start,
+		Kind:  NoPrefixParse,
+		Found: p.current,
+		Msg:   fmt.Sprintf("no prefix parse function for %s", t),
+	})
+}
+
+// / ** Infix functions
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	exp := &ast.InfixExpression{
+		Token:    p.current,
+		Operator: p.current.Literal,
+		Left:     left,
+	}
+
+	precedence := p.currentPrecedence()
+	p.nextToken()
+	exp.Right = p.parseExpression(precedence)
+	if exp.Right == nil {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.current, Function: function}
+
+	args, ok := p.parseExpressionList(token.RPAREN)
+	if !ok {
+		return nil
+	}
+	exp.Arguments = args
+
+	return exp
+}
+
+// parseExpressionList parses comma separated expressions up to the end token,
+// as found in call arguments and array literals
+func (p *Parser) parseExpressionList(end token.TokenType) ([]ast.Expression, bool) {
+	args := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return args, true
+	}
+
+	p.nextToken()
+	arg := p.parseExpression(LOWEST)
+	if arg == nil {
+		return nil, false
+	}
+	args = append(args, arg)
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+
+		arg := p.parseExpression(LOWEST)
+		if arg == nil {
+			return nil, false
+		}
+		args = append(args, arg)
+	}
+
+	if !p.expectPeek(end) {
+		return nil, false
+	}
+
+	return args, true
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.current, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+	if exp.Index == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
 }
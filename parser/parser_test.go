@@ -4,6 +4,8 @@ import (
 	"cprieto.com/monkey/ast"
 	"cprieto.com/monkey/lexer"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -47,6 +49,48 @@ let foobar = 34;
 	}
 }
 
+func TestParseLetAndReturnValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5;", "let x = 5;"},
+		{"let y = a + b * c;", "let y = (a + (b * c));"},
+		{"let z = 5", "let z = 5;"},
+		{"return 10;", "return 10;"},
+		{"return x * y", "return (x * y);"},
+		{"let a = 1 let b = 2", "let a = 1;let b = 2;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, program.String())
+		}
+	}
+}
+
+func TestMissingValueAtEOF(t *testing.T) {
+	inputs := []string{"let x =", "return", "let x"}
+
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("Expected a parsing error for `%s` but got nothing", input)
+		}
+	}
+}
+
 func TestPeekErrors(t *testing.T) {
 	input := `let x 5;`
 	l := lexer.New(input)
@@ -192,3 +236,849 @@ func TestParsingPrefixExpression(t *testing.T) {
 		}
 	}
 }
+
+func TestParsingInfixExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		left     int64
+		operator string
+		right    int64
+	}{
+		{"5 + 5;", 5, "+", 5},
+		{"5 - 5;", 5, "-", 5},
+		{"5 * 5;", 5, "*", 5},
+		{"5 / 5;", 5, "/", 5},
+		{"5 > 5;", 5, ">", 5},
+		{"5 < 5;", 5, "<", 5},
+		{"5 == 5;", 5, "==", 5},
+		{"5 != 5;", 5, "!=", 5},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected 1 statement, but got %d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Expected an expression statement but got `%T`", program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.InfixExpression)
+		if !ok {
+			t.Fatalf("stmt is not ast.InfixExpression, got=%T", stmt.Expression)
+		}
+
+		testIntegerLiteral(t, exp.Left, tt.left)
+
+		if exp.Operator != tt.operator {
+			t.Fatalf("exp.Operator is not `%s` but got=%s", tt.operator, exp.Operator)
+		}
+
+		testIntegerLiteral(t, exp.Right, tt.right)
+	}
+}
+
+func TestOperatorPrecedenceParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-a * b", "((-a) * b)"},
+		{"!-a", "(!(-a))"},
+		{"a + b + c", "((a + b) + c)"},
+		{"a + b - c", "((a + b) - c)"},
+		{"a * b * c", "((a * b) * c)"},
+		{"a * b / c", "((a * b) / c)"},
+		{"a + b / c", "(a + (b / c))"},
+		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
+		{"3 + 4; -5 * 5", "(3 + 4)((-5) * 5)"},
+		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
+		{"5 < 4 != 3 > 4", "((5 < 4) != (3 > 4))"},
+		{"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
+		{"true", "true"},
+		{"false", "false"},
+		{"3 > 5 == false", "((3 > 5) == false)"},
+		{"3 < 5 == true", "((3 < 5) == true)"},
+		{"!true", "(!true)"},
+		{"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
+		{"(5 + 5) * 2", "((5 + 5) * 2)"},
+		{"2 / (5 + 5)", "(2 / (5 + 5))"},
+		{"-(5 + 5)", "(-(5 + 5))"},
+		{"!(true == true)", "(!(true == true))"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"f(x)[0]", "(f(x)[0])"},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"a | b & c", "(a | (b & c))"},
+		{"a ^ b | c", "((a ^ b) | c)"},
+		{"1 << 2 + 3", "((1 << 2) + 3)"},
+		{"a >> b * c", "((a >> b) * c)"},
+		{"~a & b", "((~a) & b)"},
+		{"a < b || a & 1 == 0", "((a < b) || ((a & 1) == 0))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, program.String())
+		}
+	}
+}
+
+func testIntegerLiteral(t *testing.T, exp ast.Expression, value int64) {
+	t.Helper()
+
+	literal, ok := exp.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("I was expecting an integer literal but got %T", exp)
+	}
+
+	if literal.Value != value {
+		t.Fatalf("I was expecting value %d but got %d", value, literal.Value)
+	}
+
+	if literal.TokenLiteral() != fmt.Sprintf("%d", value) {
+		t.Fatalf("I was expecting literal '%d' but got '%s'", value, literal.TokenLiteral())
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	tests := []struct {
+		input       string
+		condition   string
+		consequence string
+		alternative string
+	}{
+		{"if (x < y) { x }", "(x < y)", "x", ""},
+		{"if (x < y) { x } else { y }", "(x < y)", "x", "y"},
+		{"if (x == y) then { x } else { y };", "(x == y)", "x", "y"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected 1 statement, but got %d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Expected an expression statement but got `%T`", program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.IfExpression)
+		if !ok {
+			t.Fatalf("stmt is not ast.IfExpression, got=%T", stmt.Expression)
+		}
+
+		if exp.Condition.String() != tt.condition {
+			t.Fatalf("Expected condition `%s` but got `%s`", tt.condition, exp.Condition.String())
+		}
+
+		if len(exp.Consequence.Statements) != 1 {
+			t.Fatalf("Expected 1 consequence statement, but got %d", len(exp.Consequence.Statements))
+		}
+
+		if exp.Consequence.Statements[0].String() != tt.consequence {
+			t.Fatalf("Expected consequence `%s` but got `%s`", tt.consequence, exp.Consequence.Statements[0].String())
+		}
+
+		if tt.alternative == "" {
+			if exp.Alternative != nil {
+				t.Fatalf("Expected no alternative but got `%s`", exp.Alternative.String())
+			}
+			continue
+		}
+
+		if exp.Alternative == nil || len(exp.Alternative.Statements) != 1 {
+			t.Fatalf("Expected 1 alternative statement, but got `%v`", exp.Alternative)
+		}
+
+		if exp.Alternative.Statements[0].String() != tt.alternative {
+			t.Fatalf("Expected alternative `%s` but got `%s`", tt.alternative, exp.Alternative.Statements[0].String())
+		}
+	}
+}
+
+func TestUnterminatedBlock(t *testing.T) {
+	l := lexer.New("if (x < y) { x")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("Expected a parsing error but got nothing")
+	}
+}
+
+func TestFunctionLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		params   []string
+		expected string
+	}{
+		{"fn() {};", []string{}, "fn() {  }"},
+		{"fn(x) { x };", []string{"x"}, "fn(x) { x }"},
+		{"fn(x, y) { x + y; }", []string{"x", "y"}, "fn(x, y) { (x + y) }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected 1 statement, but got %d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Expected an expression statement but got `%T`", program.Statements[0])
+		}
+
+		fn, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt is not ast.FunctionLiteral, got=%T", stmt.Expression)
+		}
+
+		if len(fn.Parameters) != len(tt.params) {
+			t.Fatalf("Expected %d parameters but got %d", len(tt.params), len(fn.Parameters))
+		}
+
+		for i, param := range tt.params {
+			if fn.Parameters[i].Value != param {
+				t.Errorf("Expected parameter `%s` but got `%s`", param, fn.Parameters[i].Value)
+			}
+		}
+
+		if fn.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, fn.String())
+		}
+	}
+}
+
+func TestCallExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(1, 2 * 3, 4 + 5);", "add(1, (2 * 3), (4 + 5))"},
+		{"noargs()", "noargs()"},
+		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
+		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
+		{"fn(x) { x }(5)", "fn(x) { x }(5)"},
+		{"twice(fn(x) { x * 2 })(3)", "twice(fn(x) { (x * 2) })(3)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, program.String())
+		}
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	inputs := []string{"fn(x, ) { x }", "fn(1) { x }", "add(1, 2", "fn(x) { x"}
+
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("Expected a parsing error for `%s` but got nothing", input)
+		}
+	}
+}
+
+func TestBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true;", true},
+		{"false;", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Expected an expression statement but got `%T`", program.Statements[0])
+		}
+
+		b, ok := stmt.Expression.(*ast.Boolean)
+		if !ok {
+			t.Fatalf("stmt is not ast.Boolean, got=%T", stmt.Expression)
+		}
+
+		if b.Value != tt.expected {
+			t.Fatalf("Expected value %t but got %t", tt.expected, b.Value)
+		}
+	}
+}
+
+func TestUnbalancedGroup(t *testing.T) {
+	l := lexer.New("(1 + 2")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("Expected a parsing error but got nothing")
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	l := lexer.New(`"hello\n\"world\""`)
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected an expression statement but got `%T`", program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("stmt is not ast.StringLiteral, got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello\n\"world\"" {
+		t.Fatalf("Expected value %q but got %q", "hello\n\"world\"", literal.Value)
+	}
+
+	if literal.String() != `"hello\n\"world\""` {
+		t.Fatalf("Expected `%s` but got `%s`", `"hello\n\"world\""`, literal.String())
+	}
+}
+
+func TestArrayLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		length   int
+		expected string
+	}{
+		{"[]", 0, "[]"},
+		{"[1]", 1, "[1]"},
+		{"[1, 2 * 2, 3 + 3]", 3, "[1, (2 * 2), (3 + 3)]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Expected an expression statement but got `%T`", program.Statements[0])
+		}
+
+		array, ok := stmt.Expression.(*ast.ArrayLiteral)
+		if !ok {
+			t.Fatalf("stmt is not ast.ArrayLiteral, got=%T", stmt.Expression)
+		}
+
+		if len(array.Elements) != tt.length {
+			t.Fatalf("Expected %d elements but got %d", tt.length, len(array.Elements))
+		}
+
+		if array.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, array.String())
+		}
+	}
+}
+
+func TestIndexExpression(t *testing.T) {
+	l := lexer.New("myArray[1 + 1]")
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected an expression statement but got `%T`", program.Statements[0])
+	}
+
+	index, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("stmt is not ast.IndexExpression, got=%T", stmt.Expression)
+	}
+
+	if index.Left.String() != "myArray" {
+		t.Fatalf("Expected left `myArray` but got `%s`", index.Left.String())
+	}
+
+	if index.Index.String() != "(1 + 1)" {
+		t.Fatalf("Expected index `(1 + 1)` but got `%s`", index.Index.String())
+	}
+}
+
+func TestArrayErrors(t *testing.T) {
+	inputs := []string{"[1, 2", "[1, ]", "a[1", "a[]"}
+
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("Expected a parsing error for `%s` but got nothing", input)
+		}
+	}
+}
+
+func TestHashLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		length   int
+		expected string
+	}{
+		{"{}", 0, "{}"},
+		{`{"one": 1, "two": 2, "three": 3}`, 3, `{"one": 1, "two": 2, "three": 3}`},
+		{`{1: true, true: "x",}`, 2, `{1: true, true: "x"}`},
+		{`{"one": 0 + 1, "two": 10 - 8}`, 2, `{"one": (0 + 1), "two": (10 - 8)}`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Expected an expression statement but got `%T`", program.Statements[0])
+		}
+
+		hash, ok := stmt.Expression.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("stmt is not ast.HashLiteral, got=%T", stmt.Expression)
+		}
+
+		if len(hash.Pairs) != tt.length {
+			t.Fatalf("Expected %d pairs but got %d", tt.length, len(hash.Pairs))
+		}
+
+		if hash.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, hash.String())
+		}
+	}
+}
+
+func TestHashAndBlockDisambiguation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`if (x) { {"a": 1} }`, `if x { {"a": 1} }`},
+		{`fn() { {} }`, `fn() { {} }`},
+		{`let h = {"a": {"b": 2}};`, `let h = {"a": {"b": 2}};`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("Expected `%s` but got `%s`", tt.expected, program.String())
+		}
+	}
+}
+
+func TestHashErrors(t *testing.T) {
+	inputs := []string{`{"a" 1}`, `{"a": 1 "b": 2}`, `{"a": }`, `{"a": 1`}
+
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("Expected a parsing error for `%s` but got nothing", input)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	input := "let x = 1;\nlet y 2;"
+	l := lexer.NewFile("main.mk", input)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("Expected a parsing error but got nothing")
+	}
+
+	expected := "main.mk:2:7: expected next token `=` but got `INT`"
+	if p.Errors()[0].Error() != expected {
+		t.Fatalf("Expected error `%s` but got `%s`", expected, p.Errors()[0])
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "let x = 1;\n  a + b * c;\n add(1)[0]"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+	}
+
+	expected := []string{"1:1", "2:3", "3:2"}
+	for i, pos := range expected {
+		stmt := program.Statements[i]
+		if stmt.Pos().String() != pos {
+			t.Errorf("Expected statement %d at `%s` but got `%s`", i, pos, stmt.Pos())
+		}
+	}
+
+	infix := program.Statements[1].(*ast.ExpressionStatement).Expression
+	if infix.Pos().String() != "2:3" {
+		t.Errorf("Expected infix expression at `2:3` but got `%s`", infix.Pos())
+	}
+
+	index := program.Statements[2].(*ast.ExpressionStatement).Expression
+	if index.Pos().String() != "3:2" {
+		t.Errorf("Expected index expression at `3:2` but got `%s`", index.Pos())
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		errors     []string
+		statements string
+	}{
+		{
+			"let x 5;\nlet y = 10;\nlet = 3;\nlet z = 1 + ;\nz;",
+			[]string{
+				"1:7: expected next token `=` but got `INT`",
+				"3:5: expected next token `IDENT` but got `=`",
+				"4:13: no prefix parse function for ;",
+			},
+			"<bad statement>let y = 10;<bad statement><bad statement>z",
+		},
+		{
+			"let f = fn(x) { let y 1; x };\nf(2);",
+			[]string{"1:23: expected next token `=` but got `INT`"},
+			"let f = fn(x) { <bad statement>x };f(2)",
+		},
+		{
+			"if (a) { 1 + } else { 2 }; b",
+			[]string{"1:14: no prefix parse function for }"},
+			"if a { <bad statement> } else { 2 }b",
+		},
+		{
+			"let x = ) let y = 2",
+			[]string{"1:9: no prefix parse function for )"},
+			"<bad statement>let y = 2;",
+		},
+		{
+			"} x",
+			[]string{"1:1: no prefix parse function for }"},
+			"<bad statement>x",
+		},
+		{
+			"let h = {\"a\" 1}; let b = 2;",
+			[]string{"1:14: expected next token `:` but got `INT`"},
+			"<bad statement>let b = 2;",
+		},
+		{
+			"let f = fn(x) { {x: } }; let q = 1;",
+			[]string{"1:21: no prefix parse function for }"},
+			"let f = fn(x) { <bad statement> };let q = 1;",
+		},
+		{
+			"if (x) { let y = {1: 2 3}; y } let z = 1;",
+			[]string{"1:24: expected next token `,` but got `INT`"},
+			"if x { <bad statement>y }let z = 1;",
+		},
+		{
+			"let a = 1 +\nlet b = 2;",
+			[]string{"2:1: no prefix parse function for LET"},
+			"<bad statement>let b = 2;",
+		},
+		{
+			"let f = fn() { 1 +\nreturn 2; }; 1 + } x",
+			[]string{
+				"2:1: no prefix parse function for RETURN",
+				"2:18: no prefix parse function for }",
+			},
+			"let f = fn() { <bad statement>return 2; };<bad statement>x",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.errors) {
+			t.Fatalf("Expected %d errors for `%s` but got %d: %v", len(tt.errors), tt.input, len(errors), errors)
+		}
+
+		for i, msg := range tt.errors {
+			if errors[i].Error() != msg {
+				t.Errorf("Expected error `%s` but got `%s`", msg, errors[i])
+			}
+		}
+
+		if program.String() != tt.statements {
+			t.Errorf("Expected `%s` but got `%s`", tt.statements, program.String())
+		}
+	}
+}
+
+func TestBadStatementSpan(t *testing.T) {
+	tests := []struct {
+		input string
+		start int
+		end   int
+	}{
+		{"let x 5;", 0, 8},
+		// recovery stops on the next statement, which is not part of the span
+		{"let a = 1 +\nlet b = 2;", 0, 11},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		bad, ok := program.Statements[0].(*ast.BadStatement)
+		if !ok {
+			t.Fatalf("Expected a bad statement but got `%T`", program.Statements[0])
+		}
+
+		if bad.Pos().Offset != tt.start || bad.End.Offset != tt.end {
+			t.Errorf("Expected bad statement spanning %d..%d but got %d..%d", tt.start, tt.end, bad.Pos().Offset, bad.End.Offset)
+		}
+	}
+}
+
+func TestCommentsAreIgnored(t *testing.T) {
+	input := `
+// adds two numbers
+let add = fn(a, b) { /* sum */ a + b }; // trailing
+add(1, /* two */ 2)
+`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+	}
+
+	expected := "let add = fn(a, b) { (a + b) };add(1, 2)"
+	if program.String() != expected {
+		t.Errorf("Expected `%s` but got `%s`", expected, program.String())
+	}
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0b1010", 10},
+		{"0o17", 15},
+		{"1_000_000", 1000000},
+		{"0x_7f", 127},
+		{"017", 17},
+		{"9223372036854775807", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("I was expecting an integer literal but got %T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("I was expecting value %d but got %d", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	inputs := []string{"9223372036854775808", "0xFFFFFFFFFFFFFFFFF", "0b1" + strings.Repeat("0", 64)}
+
+	for _, input := range inputs {
+		l := lexer.New("let x = " + input + ";")
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) != 1 {
+			t.Fatalf("Expected 1 error for `%s` but got %v", input, p.Errors())
+		}
+
+		expected := fmt.Sprintf("1:9: integer literal %s overflows int64", input)
+		if p.Errors()[0].Error() != expected {
+			t.Errorf("Expected error `%s` but got `%s`", expected, p.Errors()[0])
+		}
+	}
+}
+
+func TestNegativeIntegerLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"-0x8000000000000000", "-0x8000000000000000"},
+		{"- 9_223_372_036_854_775_808", "-9_223_372_036_854_775_808"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected for `%s`: %s", tt.input, p.Errors()[0])
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("I was expecting an integer literal but got %T", stmt.Expression)
+		}
+
+		if literal.Value != math.MinInt64 {
+			t.Errorf("I was expecting value %d but got %d", int64(math.MinInt64), literal.Value)
+		}
+		if literal.TokenLiteral() != tt.expected {
+			t.Errorf("I was expecting literal %s but got %s", tt.expected, literal.TokenLiteral())
+		}
+	}
+
+	// literals that fit are still negated by the prefix expression
+	program := New(lexer.New("-1")).ParseProgram()
+	if _, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.PrefixExpression); !ok {
+		t.Errorf("I was expecting `-1` to be a prefix expression")
+	}
+
+	p := New(lexer.New("-9223372036854775809"))
+	p.ParseProgram()
+	expected := "1:2: integer literal 9223372036854775809 overflows int64"
+	if len(p.Errors()) != 1 || p.Errors()[0].Error() != expected {
+		t.Errorf("Expected error `%s` but got %v", expected, p.Errors())
+	}
+}
+
+func TestFloatLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"2.5E3", 2500},
+		{"1_000.5", 1000.5},
+		{"1e-400", 0},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("I was expecting a float literal but got %T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("I was expecting value %g but got %g", tt.expected, literal.Value)
+		}
+	}
+
+	l := lexer.New("1e400")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 || p.Errors()[0].Kind != FloatOverflow {
+		t.Fatalf("Expected a float overflow error but got %v", p.Errors())
+	}
+}
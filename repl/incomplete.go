@@ -0,0 +1,68 @@
+package repl
+
+import (
+	"cprieto.com/monkey/lexer"
+	"cprieto.com/monkey/token"
+	"strings"
+)
+
+// continuing are the tokens that cannot end an input, something has to
+// follow them
+var continuing = map[token.TokenType]bool{
+	token.ASSIGN:    true,
+	token.PLUS:      true,
+	token.MINUS:     true,
+	token.BANG:      true,
+	token.ASTERISK:  true,
+	token.SLASH:     true,
+	token.PERCENT:   true,
+	token.LT:        true,
+	token.GT:        true,
+	token.LE:        true,
+	token.GE:        true,
+	token.EQ:        true,
+	token.NE:        true,
+	token.AND:       true,
+	token.OR:        true,
+	token.AMPERSAND: true,
+	token.PIPE:      true,
+	token.CARET:     true,
+	token.TILDE:     true,
+	token.SHL:       true,
+	token.SHR:       true,
+	token.COMMA:     true,
+	token.COLON:     true,
+	token.FUNCTION:  true,
+	token.LET:       true,
+	token.IF:        true,
+	token.THEN:      true,
+	token.ELSE:      true,
+	token.RETURN:    true,
+}
+
+// isIncomplete tells if more lines are needed to complete input: there are
+// unclosed brackets, an unterminated string or block comment, or the input
+// ends in an operator or keyword expecting something after it
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+
+	depth := 0
+	var last token.Token
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		case token.ILLEGAL:
+			// strings and block comments run to the end when unterminated
+			if tok.End.Offset == len(input) && (strings.HasPrefix(tok.Literal, `"`) || strings.HasPrefix(tok.Literal, "/*")) {
+				return true
+			}
+		}
+		last = tok
+	}
+
+	return depth > 0 || continuing[last.Type]
+}
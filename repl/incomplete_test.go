@@ -0,0 +1,34 @@
+package repl
+
+import "testing"
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n  x\n}", false},
+		{"foo(1,", true},
+		{"[1, [2, 3]", true},
+		{"}", false},
+		{`"open`, true},
+		{`"open` + "\n" + `closed"`, false},
+		{"/* comment", true},
+		{"let x =", true},
+		{"1 +", true},
+		{"a &&", true},
+		{"if (x) { 1 } else", true},
+		{"return", true},
+		{"1 + 2 // trailing comment", false},
+		{"1 @", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if actual := isIncomplete(tt.input); actual != tt.expected {
+			t.Errorf("Expected incomplete to be %t for %q but got %t", tt.expected, tt.input, actual)
+		}
+	}
+}
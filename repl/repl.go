@@ -2,30 +2,335 @@ package repl
 
 import (
 	"bufio"
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/compiler"
+	"cprieto.com/monkey/evaluator"
 	"cprieto.com/monkey/lexer"
+	"cprieto.com/monkey/lineedit"
+	"cprieto.com/monkey/object"
+	"cprieto.com/monkey/parser"
 	"cprieto.com/monkey/token"
+	"cprieto.com/monkey/vm"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT              = ">> "
+	CONTINUATION_PROMPT = ".. "
+)
+
+// Mode is what the REPL does with the input, it is switched at runtime
+// typing the mode name after a colon
+type Mode string
+
+const (
+	EvalMode   Mode = "eval"   // evaluate and print the result
+	TokensMode Mode = "tokens" // print the tokens
+	ASTMode    Mode = "ast"    // print the syntax tree
+)
+
+// Engine is what runs the input in EvalMode
+type Engine string
+
+const (
+	EvalEngine Engine = "eval" // the tree walking evaluator
+	VMEngine   Engine = "vm"   // the bytecode compiler and virtual machine
+)
+
+const help = `:eval     evaluate input and print the result (default)
+:tokens   print the tokens of the input
+:ast      print the syntax tree of the input
+:abort    discard the incomplete input typed so far
+:help     show this help
+:quit     leave the REPL
+
+Input spanning several lines is read until it is complete, Ctrl-C
+discards it. In a terminal lines can be edited, Up and Down recall the
+history and Ctrl-R searches it.
+`
+
+// session is the state kept between inputs, definitions evaluated in any
+// earlier input are still bound
+type session struct {
+	out     io.Writer
+	mode    Mode
+	engine  Engine
+	pending []string // lines of an incomplete input
+
+	env *object.Environment // of EvalEngine
+
+	// of VMEngine, each input is compiled and run on its own keeping the
+	// globals defined by the earlier ones
+	symbols   *compiler.SymbolTable
+	constants []object.Object
+	globals   []object.Object
+}
+
+func newSession(out io.Writer, engine Engine) *session {
+	return &session{
+		out:       out,
+		mode:      EvalMode,
+		engine:    engine,
+		env:       object.NewEnvironment(),
+		symbols:   compiler.NewSymbolTable(),
+		constants: []object.Object{},
+		globals:   make([]object.Object, vm.GlobalsSize),
+	}
+}
+
+// Start runs the REPL with the given engine, lines are read with a line
+// editor when both in and out are a terminal
+func Start(in io.Reader, out io.Writer, engine Engine) {
+	s := newSession(out, engine)
+
+	if isTerminal(in) && isTerminal(out) {
+		s.edit(in)
+		return
+	}
 
-func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, s.prompt())
+		if !scanner.Scan() {
+			s.flush()
+			return
+		}
+
+		if !s.feed(scanner.Text()) {
+			return
+		}
+	}
+}
+
+func isTerminal(v interface{}) bool {
+	f, ok := v.(*os.File)
+	return ok && lineedit.IsTerminal(int(f.Fd()))
+}
+
+// historyFile is where the history is kept between sessions
+func historyFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "monkey", "history"), nil
+}
+
+// edit reads the lines with a line editor, the history is loaded at the
+// start and saved when leaving
+func (s *session) edit(in io.Reader) {
+	editor := lineedit.New(in, s.out)
+
+	path, err := historyFile()
+	if err == nil {
+		err = editor.History.LoadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(s.out, "ERROR: history not loaded: %s\n", err)
+		path = "" // not to overwrite what could not be read
+	}
+
+	defer func() {
+		if path == "" {
+			return
+		}
+		if err := editor.History.SaveFile(path); err != nil {
+			fmt.Fprintf(s.out, "ERROR: history not saved: %s\n", err)
+		}
+	}()
 
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
+		line, err := editor.ReadLine(s.prompt())
+		switch {
+		case err == lineedit.ErrInterrupted:
+			s.abort()
+			continue
+		case err == io.EOF:
+			s.flush()
+			return
+		case err != nil:
+			fmt.Fprintf(s.out, "ERROR: %s\n", err)
+			return
+		}
 
-		if !scanned {
+		editor.History.Add(line)
+		if !s.feed(line) {
 			return
 		}
+	}
+}
+
+func (s *session) prompt() string {
+	if len(s.pending) > 0 {
+		return CONTINUATION_PROMPT
+	}
+	return PROMPT
+}
+
+// feed takes a line, the input is run once complete and until then lines
+// are kept pending. It returns false when the REPL has to end.
+func (s *session) feed(line string) bool {
+	// while input is pending a line like `: 1}` can continue a hash, so
+	// only the known commands are taken as such
+	if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, ":") {
+		if name := trimmed[1:]; len(s.pending) == 0 || isCommand(name) {
+			return s.command(name)
+		}
+	}
+
+	s.pending = append(s.pending, line)
+
+	input := strings.Join(s.pending, "\n")
+	if isIncomplete(input) {
+		return true
+	}
+
+	s.pending = nil
+	s.run(input)
+	return true
+}
+
+// flush runs what is pending even if incomplete, so the errors show
+func (s *session) flush() {
+	if len(s.pending) > 0 {
+		fmt.Fprintln(s.out)
+		s.run(strings.Join(s.pending, "\n"))
+		s.pending = nil
+	}
+}
+
+// abort discards the pending lines
+func (s *session) abort() {
+	s.pending = nil
+}
+
+func (s *session) run(input string) {
+	switch s.mode {
+	case TokensMode:
+		s.printTokens(input)
+	case ASTMode:
+		if program := s.parse(input); program != nil {
+			printTree(s.out, program)
+		}
+	default:
+		if program := s.parse(input); program != nil {
+			s.eval(program)
+		}
+	}
+}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+func isCommand(name string) bool {
+	switch name {
+	case string(EvalMode), string(TokensMode), string(ASTMode), "abort", "help", "quit", "q":
+		return true
+	}
+	return false
+}
+
+func (s *session) command(name string) bool {
+	switch name {
+	case string(EvalMode), string(TokensMode), string(ASTMode):
+		s.mode = Mode(name)
+	case "abort":
+		s.abort()
+	case "help":
+		fmt.Fprint(s.out, help)
+	case "quit", "q":
+		return false
+	default:
+		fmt.Fprintf(s.out, "unknown command :%s, try :help\n", name)
+	}
+	return true
+}
+
+func (s *session) printTokens(input string) {
+	l := lexer.New(input)
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%s\t%s\t%q\n", tok.Start, tok.Type, tok.Literal)
+	}
+
+	for _, err := range l.Errors() {
+		fmt.Fprintf(s.out, "ERROR: %s\n", err)
+	}
+}
 
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Fprintf(out, "%+v\n", tok)
+// parse reports the errors and returns nil when there are any
+func (s *session) parse(input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, err := range p.Errors() {
+			fmt.Fprintf(s.out, "ERROR: %s\n", err)
 		}
+		return nil
+	}
+
+	return program
+}
+
+func (s *session) eval(program *ast.Program) {
+	if s.engine == VMEngine {
+		s.runVM(program)
+		return
+	}
+
+	if evaluated := evaluator.Eval(program, s.env); evaluated != nil {
+		fmt.Fprintln(s.out, evaluated.Inspect())
 	}
 }
+
+// runVM prints the result or the error the way eval does
+func (s *session) runVM(program *ast.Program) {
+	comp := compiler.NewWithState(s.symbols, s.constants)
+	err := comp.Compile(program)
+	s.constants = comp.Bytecode().Constants
+	if err != nil {
+		fmt.Fprintf(s.out, "ERROR: %s\n", err)
+		return
+	}
+
+	machine := vm.NewWithGlobalsState(comp.Bytecode(), s.globals)
+	if err := machine.Run(); err != nil {
+		fmt.Fprintf(s.out, "ERROR: %s\n", err)
+		return
+	}
+
+	if result := machine.LastPoppedStackElem(); result != nil {
+		fmt.Fprintln(s.out, result.Inspect())
+	}
+}
+
+type treePrinter struct {
+	out   io.Writer
+	depth int
+}
+
+func (t *treePrinter) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		t.depth--
+		return nil
+	}
+
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	indent := strings.Repeat("  ", t.depth)
+
+	if _, ok := node.(*ast.Program); ok {
+		fmt.Fprintf(t.out, "%s%s\n", indent, kind)
+	} else {
+		fmt.Fprintf(t.out, "%s%s %q %s\n", indent, kind, node.TokenLiteral(), node.Pos())
+	}
+
+	t.depth++
+	return t
+}
+
+// printTree prints a node per line, children indented under their parent
+func printTree(out io.Writer, node ast.Node) {
+	ast.Walk(&treePrinter{out: out}, node)
+}
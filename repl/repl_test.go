@@ -0,0 +1,88 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func run(input string) string {
+	return runWith(EvalEngine, input)
+}
+
+func runWith(engine Engine, input string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, engine)
+	return out.String()
+}
+
+func TestEvalMode(t *testing.T) {
+	input := "let a = 5;\nlet double = fn(x) { x * 2 };\ndouble(a)\nfoo\nlet = 1\n" +
+		"let get = fn() { later };\nget()\nlet later = 3;\nget()\n1 / 0\na\n"
+
+	expected := PROMPT + PROMPT + PROMPT + "10\n" +
+		PROMPT + "ERROR: identifier not found: foo\n" +
+		PROMPT + "ERROR: 1:5: expected next token `IDENT` but got `=`\n" +
+		PROMPT + PROMPT + "ERROR: identifier not found: later\n" +
+		PROMPT + PROMPT + "3\n" +
+		PROMPT + "ERROR: division by zero\n" +
+		PROMPT + "5\n" +
+		PROMPT
+
+	// both engines keep the definitions of earlier inputs
+	for _, engine := range []Engine{EvalEngine, VMEngine} {
+		if output := runWith(engine, input); output != expected {
+			t.Errorf("Wrong output with the %s engine, expected\n%q\nbut got\n%q", engine, expected, output)
+		}
+	}
+}
+
+func TestModes(t *testing.T) {
+	input := ":tokens\nlet x\n:ast\n-a\n:eval\n1 + 1\n:what\n:quit\n2 + 2\n"
+
+	expected := PROMPT +
+		PROMPT + "1:1\tLET\t\"let\"\n1:5\tIDENT\t\"x\"\n" +
+		PROMPT +
+		PROMPT + "Program\n  ExpressionStatement \"-\" 1:1\n    PrefixExpression \"-\" 1:1\n      Identifier \"a\" 1:2\n" +
+		PROMPT +
+		PROMPT + "2\n" +
+		PROMPT + "unknown command :what, try :help\n" +
+		PROMPT
+
+	if output := run(input); output != expected {
+		t.Errorf("Wrong output, expected\n%q\nbut got\n%q", expected, output)
+	}
+}
+
+func TestModeKeepsEnvironment(t *testing.T) {
+	output := run("let a = 1;\n:ast\nlet a = 2;\n:eval\na\n")
+
+	if !strings.HasSuffix(output, PROMPT+"1\n"+PROMPT) {
+		t.Errorf("Expected `a` to be still 1 but got %q", output)
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := "let add = fn(a, b) {\n  a +\n  b\n};\nadd(1,\n2)\nlet broken = [1,\n:abort\n1 + 1\n" +
+		"let h = {\"a\"\n: 1};\nh[\"a\"]\n"
+
+	expected := PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT +
+		PROMPT + CONTINUATION_PROMPT + "3\n" +
+		PROMPT + CONTINUATION_PROMPT +
+		PROMPT + "2\n" +
+		PROMPT + CONTINUATION_PROMPT +
+		PROMPT + "1\n" +
+		PROMPT
+
+	if output := run(input); output != expected {
+		t.Errorf("Wrong output, expected\n%q\nbut got\n%q", expected, output)
+	}
+}
+
+func TestIncompleteInputAtEnd(t *testing.T) {
+	expected := PROMPT + CONTINUATION_PROMPT + "\nERROR: 1:8: expected token `}` but got `EOF`\n"
+
+	if output := run("fn(x) {\n"); output != expected {
+		t.Errorf("Wrong output, expected\n%q\nbut got\n%q", expected, output)
+	}
+}
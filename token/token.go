@@ -1,5 +1,7 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 const (
@@ -7,6 +9,9 @@ const (
 	EOF     = "EOF"
 	IDENT   = "IDENT"
 	INT     = "INT"
+	FLOAT   = "FLOAT"
+	STRING  = "STRING"
+	COMMENT = "COMMENT"
 
 	ASSIGN   = "="
 	MINUS    = "-"
@@ -14,18 +19,35 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 
 	LT = "<"
 	GT = ">"
+	LE = "<="
+	GE = ">="
+
+	AND = "&&"
+	OR  = "||"
+
+	AMPERSAND = "&"
+	PIPE      = "|"
+	CARET     = "^"
+	TILDE     = "~"
+	SHL       = "<<"
+	SHR       = ">>"
 
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
 
+	LBRACKET = "["
+	RBRACKET = "]"
+
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
 	IF       = "IF"
@@ -40,9 +62,42 @@ const (
 	NE = "!="
 )
 
+// Position is a location in a source file
+type Position struct {
+	Filename string
+	Offset   int // byte offset, starting at 0
+	Line     int // line number, starting at 1
+	Column   int // column number, starting at 1 (counted in runes)
+}
+
+// IsValid reports whether the position was set by the lexer
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String renders the position as `file:line:column`, the file name is
+// omitted when unknown and an unset position renders as `-`
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Token is a lexed token, Start is the position of its first byte and End
+// the position just past its last one
 type Token struct {
 	Type    TokenType
 	Literal string
+	Start   Position
+	End     Position
 }
 
 var keyword = map[string]TokenType{
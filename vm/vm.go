@@ -0,0 +1,593 @@
+package vm
+
+import (
+	"cprieto.com/monkey/code"
+	"cprieto.com/monkey/compiler"
+	"cprieto.com/monkey/object"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The stack and the frames grow as needed up to StackSize slots and
+// MaxFrames calls, enough for recursion deeper than the evaluator manages
+// before it runs out of Go stack, around a million calls
+const (
+	StackSize   = 1 << 23
+	GlobalsSize = 65536
+	MaxFrames   = 1 << 20
+
+	initialStackSize = 2048
+)
+
+var (
+	Null  = &object.Null{}
+	True  = &object.Boolean{Value: true}
+	False = &object.Boolean{Value: false}
+)
+
+var errStackOverflow = errors.New("stack overflow")
+
+// operators maps binary opcodes back to the source operator, runtime errors
+// read exactly like the ones from the evaluator
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShl:          "<<",
+	code.OpShr:          ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpGreaterEqual: ">=",
+	code.OpLessThan:     "<",
+	code.OpLessEqual:    "<=",
+}
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // always points to the next free slot, the top is stack[sp-1]
+
+	globals     []object.Object
+	globalNames []string
+
+	frames      []*Frame
+	framesIndex int
+
+	result object.Object // of the last top-level statement, nil after a let
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn}
+
+	frames := []*Frame{NewFrame(mainClosure, 0)}
+
+	return &VM{
+		constants:   bytecode.Constants,
+		stack:       make([]object.Object, initialStackSize),
+		sp:          0,
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// NewWithGlobalsState creates a VM that keeps the globals of previous runs,
+// so the REPL can run line by line
+func NewWithGlobalsState(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+func (vm *VM) StackTop() object.Object {
+	if vm.sp == 0 {
+		return nil
+	}
+	return vm.stack[vm.sp-1]
+}
+
+// LastPoppedStackElem is the value of the last top-level statement, nil
+// when the program ends with a let as it is for the evaluator
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.result
+}
+
+func (vm *VM) Run() error {
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip := vm.currentFrame().ip
+		ins := vm.currentFrame().Instructions()
+		op := code.Opcode(ins[ip])
+
+		var err error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			popped := vm.pop()
+			if vm.framesIndex == 1 {
+				vm.result = popped
+			}
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShl, code.OpShr,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterEqual,
+			code.OpLessThan, code.OpLessEqual:
+			err = vm.executeBinaryOperation(op)
+
+		case code.OpTrue:
+			err = vm.push(True)
+		case code.OpFalse:
+			err = vm.push(False)
+		case code.OpNull:
+			err = vm.push(Null)
+
+		case code.OpBang:
+			err = vm.push(nativeBoolToBooleanObject(!isTruthy(vm.pop())))
+		case code.OpMinus:
+			err = vm.executeMinusOperator()
+		case code.OpBitNot:
+			err = vm.executeBitNotOperator()
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpBound:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			if vm.StackTop() != nil {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			if !isTruthy(vm.pop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.globals[globalIndex] = vm.pop()
+			vm.result = nil // globals are only set by top-level lets
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			global := vm.globals[globalIndex]
+			if global == nil {
+				return fmt.Errorf("identifier not found: %s", vm.globalNames[globalIndex])
+			}
+			err = vm.push(global)
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			err = vm.push(vm.stack[frame.basePointer+int(localIndex)])
+		case code.OpGetCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			err = vm.push(vm.stack[frame.basePointer+int(localIndex)].(*object.Cell).Value)
+		case code.OpSetCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)].(*object.Cell).Value = vm.pop()
+		case code.OpMakeCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			slot := vm.currentFrame().basePointer + int(localIndex)
+			vm.stack[slot] = &object.Cell{Value: vm.stack[slot]}
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(vm.currentFrame().cl.Free[freeIndex].Value)
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(vm.currentFrame().cl.Free[freeIndex])
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+			err = vm.push(array)
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			hash := vm.buildHash(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+			err = vm.push(hash)
+		case code.OpHashKey:
+			key := vm.StackTop()
+			if _, ok := key.(object.Hashable); !ok {
+				err = fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.executeIndexExpression(left, index)
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.callFunction(int(numArgs))
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				// a return at the top level ends the program with its value
+				vm.result = returnValue
+				return nil
+			}
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err = vm.push(returnValue)
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err = vm.push(Null)
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+			err = vm.pushClosure(int(constIndex), int(numFree))
+
+		default:
+			err = fmt.Errorf("unknown opcode %d", op)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return errStackOverflow
+	}
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(o object.Object) error {
+	if err := vm.growStack(vm.sp + 1); err != nil {
+		return err
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// growStack makes room for size slots, doubling the stack up to StackSize
+func (vm *VM) growStack(size int) error {
+	if size <= len(vm.stack) {
+		return nil
+	}
+	if size > StackSize {
+		return errStackOverflow
+	}
+
+	newSize := len(vm.stack) * 2
+	for newSize < size {
+		newSize *= 2
+	}
+	if newSize > StackSize {
+		newSize = StackSize
+	}
+
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) callFunction(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	cl, ok := callee.(*object.Closure)
+	if !ok {
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments: expected %d, got %d", cl.Fn.NumParameters, numArgs)
+	}
+
+	basePointer := vm.sp - numArgs
+	if err := vm.growStack(basePointer + cl.Fn.NumLocals); err != nil {
+		return err
+	}
+	if err := vm.pushFrame(NewFrame(cl, basePointer)); err != nil {
+		return err
+	}
+
+	// locals start unbound, reads that might see them so fall back to an
+	// outer binding
+	for i := vm.sp; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) pushClosure(constIndex, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]*object.Cell, numFree)
+	for i, cell := range vm.stack[vm.sp-numFree : vm.sp] {
+		free[i] = cell.(*object.Cell)
+	}
+	vm.sp = vm.sp - numFree
+
+	return vm.push(&object.Closure{Fn: function, Free: free})
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+	copy(elements, vm.stack[startIndex:endIndex])
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		// OpHashKey has already checked the key
+		pairs[key.(object.Hashable).HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Hash{Pairs: pairs}
+}
+
+// executeIndexExpression yields null for any array index outside the array
+// and for missing hash keys
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(len(elements)) {
+			return vm.push(Null)
+		}
+		return vm.push(elements[idx])
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return fmt.Errorf("unusable as hash key: %s", index.Type())
+		}
+		pair, ok := left.(*object.Hash).Pairs[key.HashKey()]
+		if !ok {
+			return vm.push(Null)
+		}
+		return vm.push(pair.Value)
+	default:
+		return fmt.Errorf("index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+func (vm *VM) executeMinusOperator() error {
+	switch operand := vm.pop().(type) {
+	case *object.Integer:
+		return vm.push(&object.Integer{Value: -operand.Value})
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
+		return fmt.Errorf("unknown operator: -%s", operand.Type())
+	}
+}
+
+func (vm *VM) executeBitNotOperator() error {
+	operand := vm.pop()
+	if integer, ok := operand.(*object.Integer); ok {
+		return vm.push(&object.Integer{Value: ^integer.Value})
+	}
+	return fmt.Errorf("unknown operator: ~%s", operand.Type())
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return vm.executeIntegerOperation(op, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return vm.executeFloatOperation(op, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return vm.executeStringOperation(op, left, right)
+	case left.Type() != right.Type():
+		return fmt.Errorf("type mismatch: %s %s %s", left.Type(), operators[op], right.Type())
+	case op == code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case op == code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	default:
+		return unknownOperatorError(op, left, right)
+	}
+}
+
+func (vm *VM) executeIntegerOperation(op code.Opcode, left, right object.Object) error {
+	lval := left.(*object.Integer).Value
+	rval := right.(*object.Integer).Value
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.Integer{Value: lval + rval})
+	case code.OpSub:
+		return vm.push(&object.Integer{Value: lval - rval})
+	case code.OpMul:
+		return vm.push(&object.Integer{Value: lval * rval})
+	case code.OpDiv:
+		if rval == 0 {
+			return errors.New("division by zero")
+		}
+		return vm.push(&object.Integer{Value: lval / rval})
+	case code.OpMod:
+		if rval == 0 {
+			return errors.New("division by zero")
+		}
+		return vm.push(&object.Integer{Value: lval % rval})
+	case code.OpBitAnd:
+		return vm.push(&object.Integer{Value: lval & rval})
+	case code.OpBitOr:
+		return vm.push(&object.Integer{Value: lval | rval})
+	case code.OpBitXor:
+		return vm.push(&object.Integer{Value: lval ^ rval})
+	case code.OpShl, code.OpShr:
+		if rval < 0 {
+			return fmt.Errorf("negative shift count: %d", rval)
+		}
+		if op == code.OpShl {
+			return vm.push(&object.Integer{Value: lval << rval})
+		}
+		return vm.push(&object.Integer{Value: lval >> rval})
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(lval < rval))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(lval > rval))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(lval <= rval))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(lval >= rval))
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(lval == rval))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(lval != rval))
+	default:
+		return unknownOperatorError(op, left, right)
+	}
+}
+
+// executeFloatOperation pushes the result of op on two numbers of which one
+// at least is a float, both are read with object.ToFloat so the result is a
+// float or a boolean
+func (vm *VM) executeFloatOperation(op code.Opcode, left, right object.Object) error {
+	lval := object.ToFloat(left)
+	rval := object.ToFloat(right)
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.Float{Value: lval + rval})
+	case code.OpSub:
+		return vm.push(&object.Float{Value: lval - rval})
+	case code.OpMul:
+		return vm.push(&object.Float{Value: lval * rval})
+	case code.OpDiv:
+		if rval == 0 {
+			return errors.New("division by zero")
+		}
+		return vm.push(&object.Float{Value: lval / rval})
+	case code.OpMod:
+		if rval == 0 {
+			return errors.New("division by zero")
+		}
+		return vm.push(&object.Float{Value: math.Mod(lval, rval)})
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(lval < rval))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(lval > rval))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(lval <= rval))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(lval >= rval))
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(lval == rval))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(lval != rval))
+	default:
+		return unknownOperatorError(op, left, right)
+	}
+}
+
+func (vm *VM) executeStringOperation(op code.Opcode, left, right object.Object) error {
+	lval := left.(*object.String).Value
+	rval := right.(*object.String).Value
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.String{Value: lval + rval})
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(lval == rval))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(lval != rval))
+	default:
+		return unknownOperatorError(op, left, right)
+	}
+}
+
+func unknownOperatorError(op code.Opcode, left, right object.Object) error {
+	return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case Null, False:
+		return false
+	default:
+		return true
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
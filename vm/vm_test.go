@@ -0,0 +1,211 @@
+package vm
+
+import (
+	"cprieto.com/monkey/ast"
+	"cprieto.com/monkey/compiler"
+	"cprieto.com/monkey/evaluator"
+	"cprieto.com/monkey/lexer"
+	"cprieto.com/monkey/object"
+	"cprieto.com/monkey/parser"
+	"testing"
+)
+
+// TestEvaluatorParity runs every program through the evaluator and the VM,
+// both engines must agree on the result or on the error message
+func TestEvaluatorParity(t *testing.T) {
+	tests := []string{
+		// integers and operators
+		"5", "-5", "--10", "5 + 5 + 5 + 5 - 10", "2 * 2 * 2 * 2 * 2",
+		"(5 + 10 * 2 + 15 / 3) * 2 + -10", "7 % 3", "-7 % 3",
+		"-9223372036854775808", "-0x8000000000000000 + 1",
+		"12 & 10", "12 | 10", "12 ^ 10", "1 << 4", "256 >> 4", "~0", "~5 & 0xF",
+
+		// booleans and logical operators
+		"true", "false", "1 < 2", "1 > 2", "1 == 1", "1 != 1", "true == true",
+		"true != false", "(1 < 2) == true", "!true", "!!5", "1 <= 2", "3 <= 2",
+		"2 >= 2", "1.5 <= 1.5", "true && false", "false || true", "1 && 0",
+		"1 < 2 && 2 < 3", "false && undefined", "true || undefined",
+		"false && 1 / 0", "!(if (false) { 5 })", "[] == []", "let a = []; a == a",
+
+		// conditionals
+		"if (true) { 10 }", "if (false) { 10 }", "if (1 < 2) { 10 }",
+		"if (1 > 2) { 10 } else { 20 }", "if (1 < 2) then { 10 } else { 20 }",
+		"if (true) { }", "if (true) { let a = 1 }", "if (if (false) { 1 }) { 1 } else { 2 }",
+
+		// return statements
+		"return 10;", "return 10; 9;", "9; return 2 * 5; 9;",
+		"if (10 > 1) { if (10 > 1) { return 10; } return 1; }",
+
+		// errors
+		"5 + true;", "5 + true; 5;", "-true", "true + false;",
+		"if (10 > 1) { if (10 > 1) { return true + false; } return 1; }",
+		"foobar", "10 / 0", "1.5 / 0", "5 % 0", "1 << -1", "~true", "1.5 & 1",
+		"true && undefined", "false || 1 / 0", "1.5 + true", "{1.5: 1}", "5(1)",
+		`"Hello" - "World"`, `"Hello" + 1`, `[1, 2]["a"]`, `1[0]`,
+		`{"name": "Monkey"}[fn(x) { x }];`, `{[1]: 2}`, "{[1]: 1 / 0}", "{1 / 0: [1]}", "{1: 2, [3]: 4 / 0}", "fn(x) { x }(1, 2)",
+		"let x = x + 1; x", "null == null",
+
+		// programs ending with a let
+		"let x = 5;", "1; let x = 5", "let f = fn() { 1; 2 }; let y = f();",
+		"let x = if (true) { 1; 2 };", "let a = 1; return a; let b = 2;",
+		"if (true) { let a = 1 }", "",
+
+		// bindings, functions and closures
+		"let a = 5; let b = a; let c = a + b + 5; c;",
+		"let a = 1; let a = a + 1; a",
+		"let identity = fn(x) { return x; }; identity(5);",
+		"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));",
+		"fn(x) { x; }(5)", "fn() { }()", "fn() { let a = 1; }()",
+		"let adder = fn(x) { fn(y) { x + y } }; let addTwo = adder(2); addTwo(3);",
+		"let x = 10; let f = fn() { x }; f();",
+		"let x = 10; let f = fn() { x }; let x = 20; f();",
+		"let x = 10; let f = fn() { let x = 1; x }; f(); x;",
+		"let x = 10; let f = fn() { let x = x + 1; x }; f();",
+		"let compose = fn(f, g) { fn(x) { g(f(x)) } }; let inc = fn(x) { x + 1 }; compose(inc, inc)(1);",
+		"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5);",
+		"let fib = fn(n) { if (n < 2) { return n } fib(n - 1) + fib(n - 2) }; fib(15)",
+		"let depth = fn(n) { if (n == 0) { 0 } else { 1 + depth(n - 1) } }; depth(20000)",
+		"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(10)",
+		"let outer = fn() { let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(5) }; outer()",
+		"let a = fn() { let x = 1; fn() { let y = 2; fn() { x + y } } }; a()()()",
+		"fn(x) { x }", "let f = fn(x, y) { x * y }; f", "[fn() { 1 }, fn() { }]",
+		"let adder = fn(x) { fn(y) { x + y } }; adder(1)",
+		"let f = fn() { let a = fn() { b() }; let b = fn() { 1 }; a() }; f()",
+		"let f = fn() { let a = fn() { b }; let r = a(); let b = 1; r }; f()",
+		"let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; f()",
+		"let f = fn() { let x = 1; let get = fn() { x }; let x = x + 1; get() }; f()",
+		"let f = fn() { let x = 1; let inc = fn() { let x = x + 1; x }; inc() + x }; f()",
+		"let y = 5; let f = fn() { if (false) { let y = 1 }; y }; f()",
+		"let f = fn(c) { if (c) { let y = 1 }; y }; f(true)",
+		"let f = fn(c) { if (c) { let y = 1 }; y }; f(false)",
+		"let y = 5; let f = fn(c) { let g = fn() { y }; if (c) { let y = 1 }; g() }; [f(true), f(false)]",
+		"let n = 1; let f = fn() { let n = n + 1; n }; f()",
+		"let a = fn() { a }; let b = a; let a = 3; b()",
+		"let f = fn() { let r = fn(n) { if (n == 0) { 0 } else { r(n - 1) } }; let s = r; let r = 1; s(3) }; f()",
+
+		// strings, arrays and hashes
+		`"Hello" + " " + "World!"`, `"a" + "b" == "ab"`, `"a" != "b"`,
+		"[1, 2 * 2, 3 + 3]", "[[1, 2], [3, 4]][1][0]", "[1, 2, 3][3]", "[1, 2, 3][-1]",
+		"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
+		`let two = "two"; {"one": 10 - 9, two: 1 + 1, "thr" + "ee": 6 / 2, 4: 4, true: 5}`,
+		`{"foo": 5}["bar"]`, `{"a": 1, "a": 2}["a"]`, `{}`,
+
+		// floats
+		"1.5 + 1.5", "1 + 0.5", "7 / 2.0", "7 / 2", "7.5 % 2", "1e3 - 1", "-2.5",
+		"1 == 1.0", "0.1 + 0.2 == 0.3", "1e21",
+	}
+
+	for _, input := range tests {
+		program := parse(t, input)
+
+		expected := evaluator.Eval(program, object.NewEnvironment())
+		actual, err := run(program)
+
+		if expectedErr, ok := expected.(*object.Error); ok {
+			if err == nil {
+				t.Errorf("%s: expected error `%s` but got %s", input, expectedErr.Message, inspect(actual))
+			} else if err.Error() != expectedErr.Message {
+				t.Errorf("%s: expected error `%s` but got `%s`", input, expectedErr.Message, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: expected %s but got error `%s`", input, inspect(expected), err)
+			continue
+		}
+
+		// a program ending with a let has no value
+		if expected == nil || actual == nil {
+			if expected != actual {
+				t.Errorf("%s: expected %s but got %s", input, inspect(expected), inspect(actual))
+			}
+			continue
+		}
+
+		if actual.Type() != expected.Type() || actual.Inspect() != expected.Inspect() {
+			t.Errorf("%s: expected %s (%s) but got %s (%s)", input,
+				expected.Inspect(), expected.Type(), actual.Inspect(), actual.Type())
+		}
+	}
+}
+
+// inspect also describes a missing value, of programs ending with a let
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "nothing"
+	}
+	return obj.Inspect()
+}
+
+func TestRecursionStackOverflow(t *testing.T) {
+	_, err := run(parse(t, "let loop = fn() { loop() }; loop()"))
+	if err == nil || err.Error() != "stack overflow" {
+		t.Errorf("Expected error `stack overflow` but got %v", err)
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	result, err := run(parse(t, "let depth = fn(n) { if (n == 0) { 0 } else { 1 + depth(n - 1) } }; depth(500000)"))
+	if err != nil {
+		t.Fatalf("VM error not expected: %s", err)
+	}
+	if result.Inspect() != "500000" {
+		t.Errorf("Expected 500000 but got %s", result.Inspect())
+	}
+}
+
+func TestGlobalsState(t *testing.T) {
+	constants := []object.Object{}
+	symbolTable := compiler.NewSymbolTable()
+	globals := make([]object.Object, GlobalsSize)
+
+	var result object.Object
+	for _, input := range []string{"let a = 2;", "let double = fn(x) { x * a };", "double(21)"} {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(t, input)); err != nil {
+			t.Fatalf("Compile error not expected: %s", err)
+		}
+
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := NewWithGlobalsState(bytecode, globals)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("VM error not expected: %s", err)
+		}
+		result = machine.LastPoppedStackElem()
+	}
+
+	if result.Inspect() != "42" {
+		t.Errorf("Expected 42 but got %s", result.Inspect())
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parsing error not expected: %s", p.Errors()[0])
+	}
+
+	return program
+}
+
+func run(program *ast.Program) (object.Object, error) {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+
+	return machine.LastPoppedStackElem(), nil
+}